@@ -0,0 +1,188 @@
+package monarch
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// Pipeline builds an aggregation pipeline stage by stage. The first error
+// from a stage is kept and returned by Aggregate.
+type Pipeline struct {
+	stages mongo.Pipeline
+	err    error
+}
+
+func NewPipeline() *Pipeline {
+	return &Pipeline{stages: make(mongo.Pipeline, 0)}
+}
+
+// Match adds a $match stage built from the same query options as the finders.
+func (p *Pipeline) Match(query ...QueryOptions) *Pipeline {
+	q, err := newQuerier(query...)
+	if err != nil {
+		return p.fail(err)
+	}
+	return p.Stage(bson.D{{Key: "$match", Value: q.filter}})
+}
+
+// Group adds a $group stage. id is the group key expression, e.g. "$status",
+// and fields holds the accumulators, e.g. {"total": {"$sum": "$amount"}}.
+func (p *Pipeline) Group(id any, fields bson.D) *Pipeline {
+	group := append(bson.D{{Key: "_id", Value: id}}, fields...)
+	return p.Stage(bson.D{{Key: "$group", Value: group}})
+}
+
+func (p *Pipeline) Project(fields bson.D) *Pipeline {
+	return p.Stage(bson.D{{Key: "$project", Value: fields}})
+}
+
+func (p *Pipeline) AddFields(fields bson.D) *Pipeline {
+	return p.Stage(bson.D{{Key: "$addFields", Value: fields}})
+}
+
+// Unwind adds an $unwind stage for the array at path. With preserveEmpty set
+// documents whose array is missing or empty are kept.
+func (p *Pipeline) Unwind(path string, preserveEmpty bool) *Pipeline {
+	if !strings.HasPrefix(path, "$") {
+		path = "$" + path
+	}
+	return p.Stage(bson.D{{Key: "$unwind", Value: bson.D{
+		{Key: "path", Value: path},
+		{Key: "preserveNullAndEmptyArrays", Value: preserveEmpty},
+	}}})
+}
+
+// Sort adds key to the $sort stage; consecutive calls extend the same stage.
+func (p *Pipeline) Sort(key string, order OrderType) *Pipeline {
+	dir, err := order.direction()
+	if err != nil {
+		return p.fail(err)
+	}
+	if last := p.last("$sort"); last != nil {
+		last[0].Value = append(last[0].Value.(bson.D), bson.E{Key: key, Value: dir})
+		return p
+	}
+	return p.Stage(bson.D{{Key: "$sort", Value: bson.D{{Key: key, Value: dir}}}})
+}
+
+func (p *Pipeline) Lookup(from, localField, foreignField, as string) *Pipeline {
+	return p.Stage(bson.D{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: from},
+		{Key: "localField", Value: localField},
+		{Key: "foreignField", Value: foreignField},
+		{Key: "as", Value: as},
+	}}})
+}
+
+// Facet adds sub as the facet name of a $facet stage; consecutive calls
+// extend the same stage.
+func (p *Pipeline) Facet(name string, sub *Pipeline) *Pipeline {
+	if sub == nil {
+		return p.fail(errors.New("error, nil facet pipeline"))
+	}
+	if sub.err != nil {
+		return p.fail(sub.err)
+	}
+	if last := p.last("$facet"); last != nil {
+		last[0].Value = append(last[0].Value.(bson.D), bson.E{Key: name, Value: sub.stages})
+		return p
+	}
+	return p.Stage(bson.D{{Key: "$facet", Value: bson.D{{Key: name, Value: sub.stages}}}})
+}
+
+// Count adds a $count stage writing the number of documents to field.
+func (p *Pipeline) Count(field string) *Pipeline {
+	return p.Stage(bson.D{{Key: "$count", Value: field}})
+}
+
+func (p *Pipeline) Limit(limit int64) *Pipeline {
+	return p.Stage(bson.D{{Key: "$limit", Value: limit}})
+}
+
+func (p *Pipeline) Skip(skip int64) *Pipeline {
+	return p.Stage(bson.D{{Key: "$skip", Value: skip}})
+}
+
+// Stage appends a raw stage for operators the builder does not cover.
+func (p *Pipeline) Stage(stage bson.D) *Pipeline {
+	if p.err == nil {
+		p.stages = append(p.stages, stage)
+	}
+	return p
+}
+
+func (p *Pipeline) Stages() (mongo.Pipeline, error) {
+	return p.stages, p.err
+}
+
+func (p *Pipeline) fail(err error) *Pipeline {
+	if p.err == nil {
+		p.err = err
+	}
+	return p
+}
+
+func (p *Pipeline) last(op string) bson.D {
+	if len(p.stages) == 0 {
+		return nil
+	}
+	if last := p.stages[len(p.stages)-1]; last[0].Key == op {
+		return last
+	}
+	return nil
+}
+
+// Aggregate runs pipeline on c and decodes each result into R. Struct results
+// are decoded by their monarch field names, anything else by the driver. When
+// T embeds SoftDelete the pipeline only sees documents that are not deleted.
+func Aggregate[R, T any](ctx context.Context, c *Collection[T], pipeline *Pipeline) ([]R, error) {
+	if pipeline == nil {
+		return nil, errors.New("error, nil pipeline")
+	}
+	stages, err := pipeline.Stages()
+	if err != nil {
+		return nil, err
+	}
+	scope, err := c.query()
+	if err != nil {
+		return nil, err
+	}
+	if len(scope.filter) > 0 {
+		stages = append(mongo.Pipeline{{{Key: "$match", Value: scope.filter}}}, stages...)
+	}
+
+	cur, err := c.coll.Aggregate(ctx, stages)
+	if err != nil {
+		return nil, c.wrapError(err)
+	}
+	defer cur.Close(ctx)
+
+	rt := reflect.TypeFor[R]()
+	results := make([]R, 0)
+	for cur.Next(ctx) {
+		var r R
+		if rt.Kind() == reflect.Struct {
+			var doc bson.D
+			if err := cur.Decode(&doc); err != nil {
+				return nil, c.wrapError(err)
+			}
+			v, err := decodeDocument(ctx, doc, rt, c.cacheStore)
+			if err != nil {
+				return nil, err
+			}
+			r = v.Elem().Interface().(R)
+		} else if err := cur.Decode(&r); err != nil {
+			return nil, c.wrapError(err)
+		}
+		results = append(results, r)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, c.wrapError(err)
+	}
+	return results, nil
+}
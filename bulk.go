@@ -0,0 +1,272 @@
+package monarch
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const defaultBulkSize = 1000
+
+// SaveMany inserts data with a single InsertMany. The inserts are ordered, so
+// the first failure stops the remaining ones; failures are reported as a
+// *BulkError indexed by position in data, together with the IDs of the inserts
+// that succeeded, as Flush does.
+func (c *Collection[T]) SaveMany(ctx context.Context, data []T) (*Result, error) {
+	if len(data) == 0 {
+		return &Result{}, nil
+	}
+	docs := make([]any, 0, len(data))
+	for i := range data {
+		doc, err := c.insertDoc(ctx, &data[i])
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+
+	res, err := c.coll.InsertMany(ctx, docs)
+	if err != nil {
+		s, _ := c.schema()
+		if errs, ok := bulkWriteErrors(err, 0, s); ok {
+			result := &Result{}
+			if res != nil {
+				// ordered, so every insert before the failed one went through
+				result.InsertedIDs = res.InsertedIDs[:min(errs[0].Index, len(res.InsertedIDs))]
+				result.InsertedCount = int64(len(result.InsertedIDs))
+			}
+			return result, &BulkError{WriteErrors: errs}
+		}
+		return nil, c.wrapError(err)
+	}
+	for i := range data {
+		if err := runHook(ctx, &data[i], AfterSaver.AfterSave); err != nil {
+			return nil, err
+		}
+	}
+	return &Result{InsertedIDs: res.InsertedIDs, InsertedCount: int64(len(res.InsertedIDs))}, nil
+}
+
+func (c *Collection[T]) insertDoc(ctx context.Context, data *T) (bson.D, error) {
+	if err := runHook(ctx, data, BeforeSaver.BeforeSave); err != nil {
+		return nil, err
+	}
+	if err := c.touch(ctx, data, true); err != nil {
+		return nil, err
+	}
+	return c.marshal(ctx, *data, false)
+}
+
+type bulkConfig struct {
+	unordered bool
+	size      int
+}
+
+type BulkOptions func(*bulkConfig) error
+
+// Unordered lets the server apply the remaining writes after one fails.
+func Unordered() BulkOptions {
+	return func(b *bulkConfig) error {
+		b.unordered = true
+		return nil
+	}
+}
+
+// FlushSize sets how many writes are sent per BulkWrite call; defaults to 1000.
+func FlushSize(size int) BulkOptions {
+	return func(b *bulkConfig) error {
+		if size < 1 {
+			return errors.New("error, flush size must be positive")
+		}
+		b.size = size
+		return nil
+	}
+}
+
+// BulkWriter queues writes on a collection and sends them with Flush. Write
+// positions in a *BulkError count every write queued since the last Flush.
+// Before hooks, including BeforeDelete, and timestamps are applied when a
+// write is queued; After hooks are not called.
+type BulkWriter[T any] struct {
+	c      *Collection[T]
+	cfg    bulkConfig
+	models []mongo.WriteModel
+}
+
+func (c *Collection[T]) BulkWriter(opts ...BulkOptions) (*BulkWriter[T], error) {
+	cfg := bulkConfig{size: defaultBulkSize}
+	for _, opt := range opts {
+		if err := opt(&cfg); err != nil {
+			return nil, err
+		}
+	}
+	return &BulkWriter[T]{c: c, cfg: cfg}, nil
+}
+
+func (b *BulkWriter[T]) Len() int {
+	return len(b.models)
+}
+
+func (b *BulkWriter[T]) Insert(ctx context.Context, data T) error {
+	doc, err := b.c.insertDoc(ctx, &data)
+	if err != nil {
+		return err
+	}
+	b.models = append(b.models, mongo.NewInsertOneModel().SetDocument(doc))
+	return nil
+}
+
+// Update queues the same write as Collection.UpdateOneWith, including an
+// upsert with WithUpsert.
+func (b *BulkWriter[T]) Update(updates []UpdateOption, query ...QueryOptions) error {
+	return b.update(updates, false, query)
+}
+
+// UpdateMany is like Update for every document matching query.
+func (b *BulkWriter[T]) UpdateMany(updates []UpdateOption, query ...QueryOptions) error {
+	return b.update(updates, true, query)
+}
+
+func (b *BulkWriter[T]) update(updates []UpdateOption, many bool, query []QueryOptions) error {
+	cfg, err := b.c.query(query...)
+	if err != nil {
+		return err
+	}
+	u, err := newUpdater(updates...)
+	if err != nil {
+		return err
+	}
+	if err := b.c.touchUpdate(u, cfg.upsert); err != nil {
+		return err
+	}
+	if many {
+		b.models = append(b.models, mongo.NewUpdateManyModel().SetFilter(cfg.filter).SetUpdate(u.update).SetUpsert(cfg.upsert))
+	} else {
+		b.models = append(b.models, mongo.NewUpdateOneModel().SetFilter(cfg.filter).SetUpdate(u.update).SetUpsert(cfg.upsert))
+	}
+	return nil
+}
+
+// Upsert queues the same write as Collection.Upsert.
+func (b *BulkWriter[T]) Upsert(ctx context.Context, data T, query ...QueryOptions) error {
+	cfg, err := b.c.query(query...)
+	if err != nil {
+		return err
+	}
+	if err := runHook(ctx, &data, BeforeUpdater.BeforeUpdate); err != nil {
+		return err
+	}
+	update, err := b.c.updateDoc(ctx, &data, cfg.partial, true)
+	if err != nil {
+		return err
+	}
+	b.models = append(b.models, mongo.NewUpdateOneModel().SetFilter(cfg.filter).SetUpdate(update).SetUpsert(true))
+	return nil
+}
+
+func (b *BulkWriter[T]) Replace(ctx context.Context, data T, query ...QueryOptions) error {
+	cfg, err := b.c.query(query...)
+	if err != nil {
+		return err
+	}
+	if err := runHook(ctx, &data, BeforeUpdater.BeforeUpdate); err != nil {
+		return err
+	}
+	doc, pipeline, err := b.c.replacement(ctx, &data)
+	if err != nil {
+		return err
+	}
+	if pipeline != nil {
+		b.models = append(b.models, mongo.NewUpdateOneModel().SetFilter(cfg.filter).SetUpdate(pipeline).SetUpsert(cfg.upsert))
+	} else {
+		b.models = append(b.models, mongo.NewReplaceOneModel().SetFilter(cfg.filter).SetReplacement(doc).SetUpsert(cfg.upsert))
+	}
+	return nil
+}
+
+func (b *BulkWriter[T]) Delete(ctx context.Context, query ...QueryOptions) error {
+	return b.delete(ctx, false, query)
+}
+
+func (b *BulkWriter[T]) DeleteMany(ctx context.Context, query ...QueryOptions) error {
+	return b.delete(ctx, true, query)
+}
+
+// delete queues a delete, or the update setting DeletedAt when T embeds
+// SoftDelete. When T implements BeforeDeleter, the matching documents are
+// loaded and passed to it now, and the write is narrowed to them.
+func (b *BulkWriter[T]) delete(ctx context.Context, many bool, query []QueryOptions) error {
+	cfg, err := b.c.query(query...)
+	if err != nil {
+		return err
+	}
+	f, err := b.c.deletedAtField()
+	if err != nil {
+		return err
+	}
+	filter := cfg.filter
+	if _, ok := any(new(T)).(BeforeDeleter); ok {
+		var limit int64
+		if !many {
+			limit = 1
+		}
+		if _, filter, err = b.c.beforeDelete(ctx, filter, limit); err != nil {
+			return err
+		}
+	}
+	switch {
+	case f != nil && many:
+		b.models = append(b.models, mongo.NewUpdateManyModel().SetFilter(filter).SetUpdate(b.c.softDeleteUpdate(f)))
+	case f != nil:
+		b.models = append(b.models, mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(b.c.softDeleteUpdate(f)))
+	case many:
+		b.models = append(b.models, mongo.NewDeleteManyModel().SetFilter(filter))
+	default:
+		b.models = append(b.models, mongo.NewDeleteOneModel().SetFilter(filter))
+	}
+	return nil
+}
+
+// Flush sends the queued writes in batches and empties the queue. In ordered
+// mode it stops at the first failed write; unordered, every batch is sent and
+// all failures are collected. Per-write failures are returned as a *BulkError
+// together with the counts of the writes that succeeded.
+func (b *BulkWriter[T]) Flush(ctx context.Context) (*Result, error) {
+	models := b.models
+	b.models = nil
+
+	s, _ := b.c.schema()
+	result := &Result{UpsertedIDs: make(map[int]any)}
+	var writeErrs []WriteError
+	for offset := 0; offset < len(models); offset += b.cfg.size {
+		end := min(offset+b.cfg.size, len(models))
+		res, err := b.c.coll.BulkWrite(ctx, models[offset:end], options.BulkWrite().SetOrdered(!b.cfg.unordered))
+		if res != nil {
+			result.InsertedCount += res.InsertedCount
+			result.MatchedCount += res.MatchedCount
+			result.ModifiedCount += res.ModifiedCount
+			result.UpsertedCount += res.UpsertedCount
+			result.DeletedCount += res.DeletedCount
+			for i, id := range res.UpsertedIDs {
+				result.UpsertedIDs[offset+int(i)] = id
+			}
+		}
+		if err != nil {
+			errs, ok := bulkWriteErrors(err, offset, s)
+			if !ok {
+				return result, b.c.wrapError(err)
+			}
+			writeErrs = append(writeErrs, errs...)
+			if !b.cfg.unordered {
+				break
+			}
+		}
+	}
+	if len(writeErrs) > 0 {
+		return result, &BulkError{WriteErrors: writeErrs}
+	}
+	return result, nil
+}
@@ -0,0 +1,178 @@
+// Package cli implements the monarch command. A project runs it from a small
+// entrypoint, generated by "monarch init", that registers its models and
+// migrations:
+//
+//	func main() {
+//		cli.Main(func(m *monarch.Monarch) error {
+//			_, err := monarch.RegisterCollection(m, models.User{})
+//			return err
+//		})
+//	}
+//
+// Migrations are picked up from migrate.Register, so importing the package
+// that registers them is enough.
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/go-monarch/monarch"
+)
+
+// Register registers the project's collections on m. RegisterCollection does
+// not create indexes when called from the command; use "indexes apply".
+type Register func(m *monarch.Monarch) error
+
+var errUsage = errors.New("invalid usage")
+
+const usage = `usage: monarch [flags] <command> [args]
+
+commands:
+  migrate up|down|status    apply, revert or list migrations
+  migrate to <version>      migrate up or down to a version
+  indexes plan|apply        compare or sync declared and live indexes
+  schema dump <Type>        print the parsed schema of a model
+  collections list          list collections and their models
+  init                      generate a project entrypoint
+
+flags:
+`
+
+// Main runs the command with the process arguments and exits with its status.
+func Main(register Register, opts ...monarch.ConnOptions) {
+	os.Exit(Run(context.Background(), os.Args[1:], os.Stdout, os.Stderr, register, opts...))
+}
+
+// Run runs the command with args and returns the exit status: 0 on success, 1
+// on failure and 2 on invalid usage. opts are passed to monarch.Connect.
+func Run(ctx context.Context, args []string, stdout, stderr io.Writer, register Register, opts ...monarch.ConnOptions) int {
+	fs := flag.NewFlagSet("monarch", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	uri := fs.String("uri", env("MONARCH_URI", "mongodb://localhost:27017"), "connection string, $MONARCH_URI")
+	db := fs.String("db", env("MONARCH_DB", "monarch"), "database, $MONARCH_DB")
+	asJSON := fs.Bool("json", false, "print JSON instead of text")
+	timeout := fs.Duration("timeout", 5*time.Minute, "timeout of the whole command")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	cmd := &command{
+		args:     fs.Args()[1:],
+		out:      &printer{w: stdout, json: *asJSON},
+		uri:      *uri,
+		db:       *db,
+		register: register,
+		opts:     opts,
+	}
+	var err error
+	switch fs.Arg(0) {
+	case "migrate":
+		err = cmd.migrate(ctx)
+	case "indexes":
+		err = cmd.indexes(ctx)
+	case "schema":
+		err = cmd.schema(ctx)
+	case "collections":
+		err = cmd.collections(ctx)
+	case "init":
+		err = cmd.init()
+	default:
+		err = fmt.Errorf("%w: unknown command %q", errUsage, fs.Arg(0))
+	}
+
+	if err != nil {
+		fmt.Fprintf(stderr, "monarch: %v\n", err)
+		if errors.Is(err, errUsage) {
+			fs.Usage()
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+type command struct {
+	args     []string
+	out      *printer
+	uri      string
+	db       string
+	register Register
+	opts     []monarch.ConnOptions
+}
+
+// connect opens the database and registers the project's collections. The
+// returned func disconnects.
+func (cmd *command) connect(ctx context.Context) (*monarch.Monarch, func(), error) {
+	conn, err := monarch.Connect(cmd.uri, cmd.opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	closeFn := func() { conn.Disconnect(context.WithoutCancel(ctx)) }
+
+	m := monarch.New(conn)
+	m.UseDB(cmd.db)
+	m.SetAutoIndex(false)
+	if cmd.register != nil {
+		if err := cmd.register(m); err != nil {
+			closeFn()
+			return nil, nil, err
+		}
+	}
+	return m, closeFn, nil
+}
+
+// sub returns the subcommand, which must be one of valid.
+func (cmd *command) sub(name string, valid ...string) (string, error) {
+	if len(cmd.args) > 0 {
+		for _, v := range valid {
+			if cmd.args[0] == v {
+				return v, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%w: %s needs one of %v", errUsage, name, valid)
+}
+
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+// print writes v as indented JSON, or calls text to write it for humans.
+func (p *printer) print(v any, text func(w io.Writer)) error {
+	if !p.json {
+		text(p.w)
+		return nil
+	}
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func env(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-monarch/monarch"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/x/mongo/driver/drivertest"
+)
+
+type user struct {
+	Email string `monarch:"email,required" index:"unique"`
+	Role  string `monarch:"role,enum=admin|member"`
+}
+
+// mockServer makes Connect use a driver that replies to the ping and then
+// with responses, in order.
+func mockServer(responses ...bson.D) monarch.ConnOptions {
+	return func(opts *options.ClientOptions) error {
+		opts.Deployment = drivertest.NewMockDeployment(append([]bson.D{{{Key: "ok", Value: 1}}}, responses...)...)
+		return nil
+	}
+}
+
+func registerUser(m *monarch.Monarch) error {
+	_, err := monarch.RegisterCollection(m, user{})
+	return err
+}
+
+func run(args []string, register Register, opts ...monarch.ConnOptions) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := Run(context.Background(), args, &stdout, &stderr, register, opts...)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRunUsage(t *testing.T) {
+	tests := []struct {
+		args   []string
+		code   int
+		stderr string
+	}{
+		{args: nil, code: 2, stderr: "usage: monarch"},
+		{args: []string{"-h"}, code: 0, stderr: "usage: monarch"},
+		{args: []string{"-bogus"}, code: 2, stderr: "flag provided but not defined"},
+		{args: []string{"frobnicate"}, code: 2, stderr: `unknown command "frobnicate"`},
+		{args: []string{"migrate"}, code: 2, stderr: "migrate needs one of [up down status to]"},
+		{args: []string{"migrate", "sideways"}, code: 2, stderr: "migrate needs one of"},
+		{args: []string{"migrate", "to"}, code: 2, stderr: "migrate to needs a version"},
+		{args: []string{"migrate", "to", "x"}, code: 2, stderr: `invalid version "x"`},
+		{args: []string{"indexes", "plan", "-bogus"}, code: 2, stderr: "flag provided but not defined"},
+		{args: []string{"schema", "dump"}, code: 2, stderr: "schema dump needs a type or collection name"},
+		{args: []string{"collections"}, code: 2, stderr: "collections needs one of [list]"},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			code, stdout, stderr := run(tt.args, nil)
+			if code != tt.code {
+				t.Errorf("got exit %d, want %d", code, tt.code)
+			}
+			if !strings.Contains(stderr, tt.stderr) {
+				t.Errorf("stderr %q does not contain %q", stderr, tt.stderr)
+			}
+			if stdout != "" {
+				t.Errorf("got stdout %q", stdout)
+			}
+		})
+	}
+}
+
+func TestRunRegisterError(t *testing.T) {
+	register := func(*monarch.Monarch) error { return errors.New("boom") }
+	code, _, stderr := run([]string{"schema", "dump", "users"}, register, mockServer())
+	if code != 1 || !strings.Contains(stderr, "monarch: boom") {
+		t.Errorf("got exit %d, stderr %q", code, stderr)
+	}
+	if strings.Contains(stderr, "usage:") {
+		t.Error("usage printed for a failure")
+	}
+}
+
+func TestRunSchemaDump(t *testing.T) {
+	code, stdout, stderr := run([]string{"-json", "schema", "dump", "user"}, registerUser, mockServer())
+	if code != 0 {
+		t.Fatalf("got exit %d, stderr %q", code, stderr)
+	}
+	var info schemaInfo
+	if err := json.Unmarshal([]byte(stdout), &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Collection != "users" || len(info.Fields) != 2 || len(info.Indexes) != 1 {
+		t.Errorf("got %+v", info)
+	}
+	if want := []string{"index", "required"}; !reflect.DeepEqual(info.Fields[0].Flags, want) {
+		t.Errorf("got flags %v, want %v", info.Fields[0].Flags, want)
+	}
+
+	code, _, stderr = run([]string{"schema", "dump", "Order"}, registerUser, mockServer())
+	if code != 1 || !strings.Contains(stderr, `no registered model "Order"`) {
+		t.Errorf("unknown model: got exit %d, stderr %q", code, stderr)
+	}
+}
+
+func TestRunCollectionsList(t *testing.T) {
+	reply := bson.D{{Key: "ok", Value: 1}, {Key: "cursor", Value: bson.D{
+		{Key: "id", Value: int64(0)},
+		{Key: "ns", Value: "monarch.$cmd.listCollections"},
+		{Key: "firstBatch", Value: bson.A{bson.D{{Key: "name", Value: "audit"}, {Key: "type", Value: "collection"}}}},
+	}}}
+	code, stdout, stderr := run([]string{"-json", "collections", "list"}, registerUser, mockServer(reply))
+	if code != 0 {
+		t.Fatalf("got exit %d, stderr %q", code, stderr)
+	}
+	var infos []collectionInfo
+	if err := json.Unmarshal([]byte(stdout), &infos); err != nil {
+		t.Fatal(err)
+	}
+	want := []collectionInfo{
+		{Name: "audit", Type: "collection", Exists: true},
+		{Name: "users", Model: "cli.user"},
+	}
+	if !reflect.DeepEqual(infos, want) {
+		t.Errorf("got %+v, want %+v", infos, want)
+	}
+}
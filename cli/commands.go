@@ -0,0 +1,409 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-monarch/monarch"
+	"github.com/go-monarch/monarch/migrate"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type migrationInfo struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	State     string     `json:"state"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+func (cmd *command) migrate(ctx context.Context) error {
+	sub, err := cmd.sub("migrate", "up", "down", "status", "to")
+	if err != nil {
+		return err
+	}
+	var version int64
+	if sub == "to" {
+		if len(cmd.args) != 2 {
+			return fmt.Errorf("%w: migrate to needs a version", errUsage)
+		}
+		if version, err = strconv.ParseInt(cmd.args[1], 10, 64); err != nil {
+			return fmt.Errorf("%w: invalid version %q", errUsage, cmd.args[1])
+		}
+	}
+
+	m, done, err := cmd.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	mg, err := migrate.New(m)
+	if err != nil {
+		return err
+	}
+
+	var infos []migrationInfo
+	if sub == "status" {
+		status, err := mg.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range status {
+			info := migrationInfo{Version: s.Version, Name: s.Name, State: "pending"}
+			if s.Applied {
+				info.State, info.AppliedAt = "applied", &s.AppliedAt
+			}
+			if s.Missing {
+				info.State = "missing"
+			}
+			infos = append(infos, info)
+		}
+	} else {
+		var ran []migrate.Migration
+		switch sub {
+		case "up":
+			ran, err = mg.Up(ctx)
+		case "down":
+			ran, err = mg.Down(ctx)
+		case "to":
+			ran, err = mg.To(ctx, version)
+		}
+		for _, mig := range ran {
+			state := "reverted"
+			if sub == "up" || (sub == "to" && mig.Version <= version) {
+				state = "applied"
+			}
+			infos = append(infos, migrationInfo{Version: mig.Version, Name: mig.Name, State: state})
+		}
+		// Report the steps that ran before a failure too.
+		if err != nil {
+			cmd.out.print(infos, func(w io.Writer) { printMigrations(w, infos, false) })
+			return err
+		}
+	}
+
+	return cmd.out.print(infos, func(w io.Writer) { printMigrations(w, infos, sub == "status") })
+}
+
+func printMigrations(w io.Writer, infos []migrationInfo, status bool) {
+	if len(infos) == 0 {
+		if status {
+			fmt.Fprintln(w, "no migrations")
+		} else {
+			fmt.Fprintln(w, "nothing to migrate")
+		}
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+	for _, info := range infos {
+		appliedAt := ""
+		if info.AppliedAt != nil {
+			appliedAt = info.AppliedAt.Local().Format(time.DateTime)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", info.Version, info.Name, info.State, appliedAt)
+	}
+	tw.Flush()
+}
+
+type indexChange struct {
+	Collection string          `json:"collection"`
+	Action     string          `json:"action"`
+	Name       string          `json:"name"`
+	Keys       json.RawMessage `json:"keys,omitempty"`
+}
+
+type indexPlan struct {
+	Applied bool          `json:"applied"`
+	Changes []indexChange `json:"changes"`
+}
+
+func (cmd *command) indexes(ctx context.Context) error {
+	sub, err := cmd.sub("indexes", "plan", "apply")
+	if err != nil {
+		return err
+	}
+	fs := flag.NewFlagSet("indexes "+sub, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	keepUnknown := fs.Bool("keep-unknown", false, "keep live indexes no schema declares")
+	if err := fs.Parse(cmd.args[1:]); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+
+	m, done, err := cmd.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	plan, err := m.SyncIndexes(ctx, monarch.SyncOptions{
+		Apply:       sub == "apply",
+		KeepUnknown: *keepUnknown,
+		Collections: fs.Args(),
+	})
+	if err != nil {
+		return err
+	}
+
+	out := indexPlan{Applied: sub == "apply", Changes: []indexChange{}}
+	for _, ch := range plan.Changes {
+		spec := ch.Declared
+		if spec == nil {
+			spec = ch.Live
+		}
+		out.Changes = append(out.Changes, indexChange{
+			Collection: ch.Collection,
+			Action:     string(ch.Action),
+			Name:       ch.Name,
+			Keys:       extJSON(indexKeys(spec)),
+		})
+	}
+	return cmd.out.print(out, func(w io.Writer) {
+		if len(out.Changes) == 0 {
+			fmt.Fprintln(w, "indexes in sync")
+			return
+		}
+		for i, ch := range out.Changes {
+			fmt.Fprintf(w, "%s %s\n", plan.Changes[i], ch.Keys)
+		}
+		if !out.Applied {
+			fmt.Fprintf(w, "%d changes, run \"indexes apply\" to execute them\n", len(out.Changes))
+		}
+	})
+}
+
+func indexKeys(spec *monarch.IndexSpec) bson.D {
+	if spec == nil {
+		return nil
+	}
+	keys := bson.D{}
+	for _, k := range spec.Keys {
+		keys = append(keys, bson.E{Key: k.Field, Value: k.Value})
+	}
+	return keys
+}
+
+type fieldInfo struct {
+	Name   string   `json:"name"`
+	DBName string   `json:"db_name"`
+	Type   string   `json:"type"`
+	Flags  []string `json:"flags,omitempty"`
+}
+
+type indexInfo struct {
+	Name    string          `json:"name"`
+	Keys    json.RawMessage `json:"keys"`
+	Unique  bool            `json:"unique,omitempty"`
+	Sparse  bool            `json:"sparse,omitempty"`
+	TTL     string          `json:"ttl,omitempty"`
+	Partial json.RawMessage `json:"partial,omitempty"`
+}
+
+type schemaInfo struct {
+	Name       string          `json:"name"`
+	Type       string          `json:"type"`
+	Collection string          `json:"collection"`
+	Fields     []fieldInfo     `json:"fields"`
+	Indexes    []indexInfo     `json:"indexes"`
+	Relations  []string        `json:"relations,omitempty"`
+	JSONSchema json.RawMessage `json:"json_schema"`
+}
+
+func (cmd *command) schema(ctx context.Context) error {
+	if _, err := cmd.sub("schema", "dump"); err != nil {
+		return err
+	}
+	if len(cmd.args) != 2 {
+		return fmt.Errorf("%w: schema dump needs a type or collection name", errUsage)
+	}
+	name := cmd.args[1]
+
+	m, done, err := cmd.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	var s *monarch.Schema
+	for _, schema := range m.Schemas() {
+		if schema.Name == name || schema.SchemaType.String() == name || schema.Collection == name {
+			s = schema
+			break
+		}
+	}
+	if s == nil {
+		return fmt.Errorf("no registered model %q", name)
+	}
+
+	info := schemaInfo{
+		Name:       s.Name,
+		Type:       s.SchemaType.String(),
+		Collection: s.Collection,
+		Indexes:    []indexInfo{},
+		JSONSchema: extJSON(s.JSONSchema()),
+	}
+	for _, f := range s.Fields {
+		if f.DBName == "" {
+			continue
+		}
+		info.Fields = append(info.Fields, fieldInfo{Name: f.Name, DBName: f.DBName, Type: f.FieldType.String(), Flags: fieldFlags(f)})
+	}
+	for _, spec := range s.Indexes {
+		idx := indexInfo{Name: spec.Name, Keys: extJSON(indexKeys(&spec)), Unique: spec.Unique, Sparse: spec.Sparse}
+		if spec.TTL > 0 {
+			idx.TTL = spec.TTL.String()
+		}
+		if len(spec.Partial) > 0 {
+			idx.Partial = extJSON(spec.Partial)
+		}
+		info.Indexes = append(info.Indexes, idx)
+	}
+	for name, rel := range s.Relations {
+		info.Relations = append(info.Relations, fmt.Sprintf("%s -> %s.%s", name, rel.Collection, rel.ForeignKey))
+	}
+	slices.Sort(info.Relations)
+
+	return cmd.out.print(info, func(w io.Writer) {
+		fmt.Fprintf(w, "%s (%s) -> collection %s\n\n", info.Name, info.Type, info.Collection)
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "FIELD\tDB NAME\tTYPE\tFLAGS")
+		for _, f := range info.Fields {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", f.Name, f.DBName, f.Type, strings.Join(f.Flags, ","))
+		}
+		tw.Flush()
+		if len(info.Indexes) > 0 {
+			fmt.Fprintln(w, "\nindexes:")
+			for _, idx := range info.Indexes {
+				fmt.Fprintf(w, "  %s %s", idx.Name, idx.Keys)
+				if idx.Unique {
+					fmt.Fprint(w, " unique")
+				}
+				if idx.Sparse {
+					fmt.Fprint(w, " sparse")
+				}
+				if idx.TTL != "" {
+					fmt.Fprintf(w, " ttl=%s", idx.TTL)
+				}
+				if idx.Partial != nil {
+					fmt.Fprintf(w, " partial=%s", idx.Partial)
+				}
+				fmt.Fprintln(w)
+			}
+		}
+		if len(info.Relations) > 0 {
+			fmt.Fprintln(w, "\nrelations:")
+			for _, rel := range info.Relations {
+				fmt.Fprintf(w, "  %s\n", rel)
+			}
+		}
+	})
+}
+
+func fieldFlags(f *monarch.Field) []string {
+	var flags []string
+	for _, flag := range []struct {
+		name string
+		on   bool
+	}{
+		{"index", f.Index},
+		{"omitempty", f.OmitEmpty},
+		{"autoCreateTime", f.AutoCreateTime},
+		{"autoUpdateTime", f.AutoUpdateTime},
+		{"softDelete", f.SoftDelete},
+		{"required", f.Required},
+	} {
+		if flag.on {
+			flags = append(flags, flag.name)
+		}
+	}
+	if f.Ref != "" {
+		flags = append(flags, "ref="+f.Ref)
+	}
+	if f.Rel != "" {
+		flags = append(flags, "rel="+f.Rel)
+	}
+	if len(f.Enum) > 0 {
+		flags = append(flags, "enum="+strings.Join(f.Enum, "|"))
+	}
+	if f.Min != nil {
+		flags = append(flags, "min="+strconv.FormatFloat(*f.Min, 'g', -1, 64))
+	}
+	if f.Max != nil {
+		flags = append(flags, "max="+strconv.FormatFloat(*f.Max, 'g', -1, 64))
+	}
+	return flags
+}
+
+type collectionInfo struct {
+	Name   string `json:"name"`
+	Type   string `json:"type,omitempty"`
+	Model  string `json:"model,omitempty"`
+	Exists bool   `json:"exists"`
+}
+
+func (cmd *command) collections(ctx context.Context) error {
+	if _, err := cmd.sub("collections", "list"); err != nil {
+		return err
+	}
+
+	m, done, err := cmd.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	specs, err := m.Database().ListCollectionSpecifications(ctx, bson.D{})
+	if err != nil {
+		return err
+	}
+	byName := map[string]*collectionInfo{}
+	for _, spec := range specs {
+		byName[spec.Name] = &collectionInfo{Name: spec.Name, Type: spec.Type, Exists: true}
+	}
+	for _, s := range m.Schemas() {
+		info, ok := byName[s.Collection]
+		if !ok {
+			info = &collectionInfo{Name: s.Collection}
+			byName[s.Collection] = info
+		}
+		info.Model = s.SchemaType.String()
+	}
+
+	infos := []collectionInfo{}
+	for _, info := range byName {
+		infos = append(infos, *info)
+	}
+	slices.SortFunc(infos, func(a, b collectionInfo) int { return strings.Compare(a.Name, b.Name) })
+
+	return cmd.out.print(infos, func(w io.Writer) {
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tTYPE\tMODEL")
+		for _, info := range infos {
+			typ := info.Type
+			if !info.Exists {
+				typ = "(missing)"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", info.Name, typ, info.Model)
+		}
+		tw.Flush()
+	})
+}
+
+// extJSON renders v as relaxed extended JSON, which keeps bson.D key order.
+func extJSON(v bson.D) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	b, err := bson.MarshalExtJSON(v, false, false)
+	if err != nil {
+		return nil
+	}
+	return b
+}
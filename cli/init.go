@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+var entrypoint = template.Must(template.New("main").Parse(`// Command monarch runs the monarch CLI against the models and migrations of
+// {{.Module}}. Generated by "monarch init".
+package main
+
+import (
+	"github.com/go-monarch/monarch"
+	"github.com/go-monarch/monarch/cli"
+	// Import the packages that call migrate.Register, e.g.
+	// _ "{{.Module}}/migrations"
+)
+
+func main() {
+	cli.Main(register)
+}
+
+// register registers the collections the commands work on, e.g.
+//
+//	_, err := monarch.RegisterCollection(m, models.User{})
+func register(m *monarch.Monarch) error {
+	return nil
+}
+`))
+
+// init writes the project entrypoint, cmd/monarch/main.go by default.
+func (cmd *command) init() error {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	out := fs.String("o", filepath.Join("cmd", "monarch", "main.go"), "file to write")
+	force := fs.Bool("force", false, "overwrite an existing file")
+	if err := fs.Parse(cmd.args); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+
+	if _, err := os.Stat(*out); err == nil && !*force {
+		return fmt.Errorf("%s already exists, use -force to overwrite it", *out)
+	}
+	module, err := modulePath(filepath.Dir(*out))
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := entrypoint.Execute(&buf, struct{ Module string }{module}); err != nil {
+		return err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(*out), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		return err
+	}
+
+	return cmd.out.print(struct {
+		File   string `json:"file"`
+		Module string `json:"module"`
+	}{*out, module}, func(w io.Writer) {
+		fmt.Fprintf(w, "wrote %s, register your models there and run it with go run ./%s\n", *out, filepath.Dir(*out))
+	})
+}
+
+// modulePath returns the module of the nearest go.mod above dir.
+func modulePath(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		f, err := os.Open(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			defer f.Close()
+			scanner := bufio.NewScanner(f)
+			for scanner.Scan() {
+				if module, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "module "); ok {
+					return strings.Trim(strings.TrimSpace(module), `"`), nil
+				}
+			}
+			return "", fmt.Errorf("%s has no module directive", f.Name())
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", errors.New("no go.mod found, run init inside a Go module")
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,10 @@
+// Command monarch migrates, syncs indexes and inspects schemas of a monarch
+// database. Without a project entrypoint it knows no models; run
+// "monarch init" inside a module to generate one that registers them.
+package main
+
+import "github.com/go-monarch/monarch/cli"
+
+func main() {
+	cli.Main(nil)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"iter"
 	"reflect"
 	"sync"
 	"time"
@@ -17,87 +18,138 @@ import (
 type Collection[T any] struct {
 	coll       *mongo.Collection
 	cacheStore *sync.Map
-	Model[T]
+	now        func() time.Time
 }
 
+var _ Model[struct{}] = (*Collection[struct{}])(nil)
+
 type Model[T any] interface {
-	ExecRaw(ctx context.Context, f func(coll *Collection[T]) error) (*T, error)
+	ExecRaw(ctx context.Context, f func(ctx context.Context, coll *mongo.Collection) error) error
 	CreateIndex(ctx context.Context) error
 	FindOne(ctx context.Context, query ...QueryOptions) (*T, error)
 	FindMany(ctx context.Context, query ...QueryOptions) ([]*T, error)
-	UpdateOne(ctx context.Context, data T, query ...QueryOptions) error
-	UpdateMany(ctx context.Context, data T, query ...QueryOptions) error
-	DeleteOne(ctx context.Context, query ...QueryOptions) error
-	DeleteMany(ctx context.Context, query ...QueryOptions) error
-	Save(ctx context.Context, data T) error
+	FindCursor(ctx context.Context, query ...QueryOptions) (*Cursor[T], error)
+	Iter(ctx context.Context, query ...QueryOptions) iter.Seq2[*T, error]
+	Watch(ctx context.Context, query ...QueryOptions) (*ChangeStream[T], error)
+	Paginate(ctx context.Context, pageSize int64, cursor string, query ...QueryOptions) (Page[T], error)
+	Count(ctx context.Context, query ...QueryOptions) (int64, error)
+	Exists(ctx context.Context, query ...QueryOptions) (bool, error)
+	EstimatedCount(ctx context.Context) (int64, error)
+	UpdateOne(ctx context.Context, data T, query ...QueryOptions) (*Result, error)
+	UpdateMany(ctx context.Context, data T, query ...QueryOptions) (*Result, error)
+	UpdateOneWith(ctx context.Context, updates []UpdateOption, query ...QueryOptions) (*Result, error)
+	UpdateManyWith(ctx context.Context, updates []UpdateOption, query ...QueryOptions) (*Result, error)
+	Upsert(ctx context.Context, data T, query ...QueryOptions) (*Result, error)
+	ReplaceOne(ctx context.Context, data T, query ...QueryOptions) (*Result, error)
+	FindOneAndUpdate(ctx context.Context, updates []UpdateOption, query ...QueryOptions) (*T, error)
+	FindOneAndReplace(ctx context.Context, data T, query ...QueryOptions) (*T, error)
+	FindOneAndDelete(ctx context.Context, query ...QueryOptions) (*T, error)
+	DeleteOne(ctx context.Context, query ...QueryOptions) (*Result, error)
+	DeleteMany(ctx context.Context, query ...QueryOptions) (*Result, error)
+	Restore(ctx context.Context, query ...QueryOptions) (*Result, error)
+	ForceDelete(ctx context.Context, query ...QueryOptions) (*Result, error)
+	Save(ctx context.Context, data T) (*Result, error)
 }
 
-func RegisterCollection[T any](m *Monarch, schema T) (*Collection[T], error) {
+func RegisterCollection[T any](m *Monarch, schema T, opts ...RegisterOptions) (*Collection[T], error) {
+	var cfg registerConfig
+	for _, opt := range opts {
+		if err := opt(&cfg); err != nil {
+			return nil, err
+		}
+	}
 	s, err := parse(schema, m.cacheStore)
 	if err != nil {
 		return nil, err
 	}
 
+	if cfg.validator {
+		if err := applyValidator(context.Background(), m.db, s, cfg); err != nil {
+			return nil, err
+		}
+	}
 	coll := m.db.Collection(s.Collection)
-	if err := registerIndexes(coll, s.Fields); err != nil {
-		return nil, err
+	if !m.noIndexes {
+		if err := registerIndexes(context.Background(), coll, s.Indexes); err != nil {
+			return nil, err
+		}
 	}
-	c := &Collection[T]{coll: coll, cacheStore: m.cacheStore}
+	m.schemas.Store(s.Collection, s)
+	c := &Collection[T]{coll: coll, cacheStore: m.cacheStore, now: m.now}
 
 	return c, nil
 }
 
-func (c *Collection[T]) Save(ctx context.Context, data T) error {
+// ExecRaw runs f against the underlying driver collection for operations
+// monarch does not cover. f receives the same ctx as the call, and its error
+// is wrapped like those of the other methods, e.g. into ErrNotFound.
+func (c *Collection[T]) ExecRaw(ctx context.Context, f func(ctx context.Context, coll *mongo.Collection) error) error {
+	if f == nil {
+		return errors.New("error, nil raw exec func")
+	}
+	return c.wrapError(f(ctx, c.coll))
+}
 
-	val, err := c.marshal(ctx, data)
+// CreateIndex creates the indexes declared on the schema of T.
+func (c *Collection[T]) CreateIndex(ctx context.Context) error {
+	s, err := c.schema()
 	if err != nil {
 		return err
 	}
-	if _, err := c.coll.InsertOne(ctx, val); err != nil {
-		return err
+	return registerIndexes(ctx, c.coll, s.Indexes)
+}
+
+func (c *Collection[T]) Save(ctx context.Context, data T) (*Result, error) {
+	if err := runHook(ctx, &data, BeforeSaver.BeforeSave); err != nil {
+		return nil, err
 	}
-	return nil
+	if err := c.touch(ctx, &data, true); err != nil {
+		return nil, err
+	}
+	val, err := c.marshal(ctx, data, false)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.coll.InsertOne(ctx, val)
+	if err != nil {
+		return nil, c.wrapError(err)
+	}
+	if err := runHook(ctx, &data, AfterSaver.AfterSave); err != nil {
+		return nil, err
+	}
+	return insertResult(res), nil
 }
 
 func (c *Collection[T]) FindOne(ctx context.Context, query ...QueryOptions) (*T, error) {
-	cfg := &querier{
-		filter: make(bson.D, 0),
-	}
-	for _, q := range query {
-		if err := q(cfg); err != nil {
-			return nil, err
-		}
+	cfg, err := c.query(query...)
+	if err != nil {
+		return nil, err
 	}
 
 	var single bson.D
 	if err := c.coll.FindOne(ctx, cfg.filter).Decode(&single); err != nil {
-		return nil, err
+		return nil, c.wrapError(err)
 	}
-	res, err := c.unMarshal(single)
+	res, err := c.decode(ctx, single)
 	if err != nil {
 		return nil, err
 	}
+	if err := c.preload(ctx, []*T{res}, cfg.preload); err != nil {
+		return nil, err
+	}
 	return res, nil
 }
 
 func (c *Collection[T]) FindMany(ctx context.Context, query ...QueryOptions) ([]*T, error) {
-	cfg := &querier{
-		filter: make(bson.D, 0),
-		order:  make(bson.D, 0),
-		limit:  0,
-		offset: 0,
-	}
-	for _, q := range query {
-		if err := q(cfg); err != nil {
-			return nil, err
-		}
+	cfg, err := c.query(query...)
+	if err != nil {
+		return nil, err
 	}
 
 	var findResult []*T
-	result, err := c.coll.Find(ctx, cfg.filter, options.Find().SetLimit(cfg.limit).
-		SetSkip(cfg.offset).SetSort(cfg.order))
+	result, err := c.coll.Find(ctx, cfg.filter, cfg.findOptions())
 	if err != nil {
-		return nil, err
+		return nil, c.wrapError(err)
 	}
 
 	defer result.Close(ctx)
@@ -106,110 +158,470 @@ func (c *Collection[T]) FindMany(ctx context.Context, query ...QueryOptions) ([]
 		var res bson.D
 
 		if err := result.Decode(&res); err != nil {
-			return nil, err
+			return nil, c.wrapError(err)
 		}
 
-		val, err := c.unMarshal(res)
+		val, err := c.decode(ctx, res)
 		if err != nil {
 			return nil, err
 		}
 		findResult = append(findResult, val)
 	}
+	if err := result.Err(); err != nil {
+		return nil, c.wrapError(err)
+	}
+	if err := c.preload(ctx, findResult, cfg.preload); err != nil {
+		return nil, err
+	}
 	return findResult, nil
 }
 
-func (c *Collection[T]) UpdateOne(ctx context.Context, data T, query ...QueryOptions) error {
-	cfg := &querier{
-		filter: make(bson.D, 0),
-		order:  make(bson.D, 0),
-		limit:  0,
-		offset: 0,
+func (c *Collection[T]) Count(ctx context.Context, query ...QueryOptions) (int64, error) {
+	cfg, err := c.query(query...)
+	if err != nil {
+		return 0, err
+	}
+
+	opts := options.Count()
+	if cfg.limit > 0 {
+		opts.SetLimit(cfg.limit)
 	}
-	for _, q := range query {
-		if err := q(cfg); err != nil {
-			return err
-		}
+	if cfg.offset > 0 {
+		opts.SetSkip(cfg.offset)
 	}
-	val, err := c.marshal(ctx, data)
+	n, err := c.coll.CountDocuments(ctx, cfg.filter, opts)
 	if err != nil {
-		return err
+		return 0, c.wrapError(err)
 	}
+	return n, nil
+}
 
-	_, err = c.coll.UpdateOne(ctx, cfg.filter, bson.D{{Key: "$set", Value: val}})
-	return err
+func (c *Collection[T]) Exists(ctx context.Context, query ...QueryOptions) (bool, error) {
+	n, err := c.Count(ctx, append(query[:len(query):len(query)], Limit(1))...)
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
 }
-func (c *Collection[T]) UpdateMany(ctx context.Context, data T, query ...QueryOptions) error {
-	cfg := &querier{
-		filter: make(bson.D, 0),
-		order:  make(bson.D, 0),
-		limit:  0,
-		offset: 0,
+
+// EstimatedCount returns the collection's document count from its metadata,
+// without applying any filter.
+func (c *Collection[T]) EstimatedCount(ctx context.Context) (int64, error) {
+	n, err := c.coll.EstimatedDocumentCount(ctx)
+	if err != nil {
+		return 0, c.wrapError(err)
 	}
-	for _, q := range query {
-		if err := q(cfg); err != nil {
-			return err
-		}
+	return n, nil
+}
+
+// Distinct returns the distinct values of field among the documents matching
+// query, decoded as V.
+func Distinct[V, T any](ctx context.Context, c *Collection[T], field string, query ...QueryOptions) ([]V, error) {
+	cfg, err := c.query(query...)
+	if err != nil {
+		return nil, err
 	}
 
-	val, err := c.marshal(ctx, data)
+	values := make([]V, 0)
+	if err := c.coll.Distinct(ctx, field, cfg.filter).Decode(&values); err != nil {
+		return nil, c.wrapError(err)
+	}
+	return values, nil
+}
+
+func (c *Collection[T]) UpdateOne(ctx context.Context, data T, query ...QueryOptions) (*Result, error) {
+	cfg, err := c.query(query...)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := runHook(ctx, &data, BeforeUpdater.BeforeUpdate); err != nil {
+		return nil, err
+	}
+	update, err := c.updateDoc(ctx, &data, cfg.partial, false)
+	if err != nil {
+		return nil, err
 	}
 
-	_, err = c.coll.UpdateMany(ctx, cfg.filter, bson.D{{Key: "$set", Value: val}})
+	res, err := c.coll.UpdateOne(ctx, cfg.filter, update)
+	if err != nil {
+		return nil, c.wrapError(err)
+	}
+	if err := runHook(ctx, &data, AfterUpdater.AfterUpdate); err != nil {
+		return nil, err
+	}
+	return updateResult(res), nil
+}
+func (c *Collection[T]) UpdateMany(ctx context.Context, data T, query ...QueryOptions) (*Result, error) {
+	cfg, err := c.query(query...)
+	if err != nil {
+		return nil, err
+	}
+	if err := runHook(ctx, &data, BeforeUpdater.BeforeUpdate); err != nil {
+		return nil, err
+	}
+	update, err := c.updateDoc(ctx, &data, cfg.partial, false)
+	if err != nil {
+		return nil, err
+	}
 
-	return err
+	res, err := c.coll.UpdateMany(ctx, cfg.filter, update)
+	if err != nil {
+		return nil, c.wrapError(err)
+	}
+	if err := runHook(ctx, &data, AfterUpdater.AfterUpdate); err != nil {
+		return nil, err
+	}
+	return updateResult(res), nil
 }
-func (c *Collection[T]) DeleteOne(ctx context.Context, query ...QueryOptions) error {
-	cfg := &querier{
-		filter: make(bson.D, 0),
-		order:  make(bson.D, 0),
-		limit:  0,
-		offset: 0,
+
+func (c *Collection[T]) UpdateOneWith(ctx context.Context, updates []UpdateOption, query ...QueryOptions) (*Result, error) {
+	cfg, err := c.query(query...)
+	if err != nil {
+		return nil, err
 	}
-	for _, q := range query {
-		if err := q(cfg); err != nil {
-			return err
+	u, err := newUpdater(updates...)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.touchUpdate(u, cfg.upsert); err != nil {
+		return nil, err
+	}
+
+	res, err := c.coll.UpdateOne(ctx, cfg.filter, u.update, options.UpdateOne().SetUpsert(cfg.upsert))
+	if err != nil {
+		return nil, c.wrapError(err)
+	}
+	return updateResult(res), nil
+}
+
+func (c *Collection[T]) UpdateManyWith(ctx context.Context, updates []UpdateOption, query ...QueryOptions) (*Result, error) {
+	cfg, err := c.query(query...)
+	if err != nil {
+		return nil, err
+	}
+	u, err := newUpdater(updates...)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.touchUpdate(u, false); err != nil {
+		return nil, err
+	}
+
+	res, err := c.coll.UpdateMany(ctx, cfg.filter, u.update)
+	if err != nil {
+		return nil, c.wrapError(err)
+	}
+	return updateResult(res), nil
+}
+
+// Upsert sets the fields of data on the document matching query, inserting
+// it when there is none.
+func (c *Collection[T]) Upsert(ctx context.Context, data T, query ...QueryOptions) (*Result, error) {
+	cfg, err := c.query(query...)
+	if err != nil {
+		return nil, err
+	}
+	if err := runHook(ctx, &data, BeforeUpdater.BeforeUpdate); err != nil {
+		return nil, err
+	}
+	update, err := c.updateDoc(ctx, &data, cfg.partial, true)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.coll.UpdateOne(ctx, cfg.filter, update, options.UpdateOne().SetUpsert(true))
+	if err != nil {
+		return nil, c.wrapError(err)
+	}
+	if err := runHook(ctx, &data, AfterUpdater.AfterUpdate); err != nil {
+		return nil, err
+	}
+	return updateResult(res), nil
+}
+
+func (c *Collection[T]) ReplaceOne(ctx context.Context, data T, query ...QueryOptions) (*Result, error) {
+	cfg, err := c.query(query...)
+	if err != nil {
+		return nil, err
+	}
+	if err := runHook(ctx, &data, BeforeUpdater.BeforeUpdate); err != nil {
+		return nil, err
+	}
+	doc, pipeline, err := c.replacement(ctx, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	var res *mongo.UpdateResult
+	if pipeline != nil {
+		res, err = c.coll.UpdateOne(ctx, cfg.filter, pipeline, options.UpdateOne().SetUpsert(cfg.upsert))
+	} else {
+		res, err = c.coll.ReplaceOne(ctx, cfg.filter, doc, options.Replace().SetUpsert(cfg.upsert))
+	}
+	if err != nil {
+		return nil, c.wrapError(err)
+	}
+	if err := runHook(ctx, &data, AfterUpdater.AfterUpdate); err != nil {
+		return nil, err
+	}
+	return updateResult(res), nil
+}
+
+func (c *Collection[T]) FindOneAndUpdate(ctx context.Context, updates []UpdateOption, query ...QueryOptions) (*T, error) {
+	cfg, err := c.query(query...)
+	if err != nil {
+		return nil, err
+	}
+	u, err := newUpdater(updates...)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.touchUpdate(u, cfg.upsert); err != nil {
+		return nil, err
+	}
+
+	opts := options.FindOneAndUpdate().SetUpsert(cfg.upsert).SetReturnDocument(cfg.returnDocument())
+	if len(cfg.order) > 0 {
+		opts.SetSort(cfg.order)
+	}
+	var single bson.D
+	if err := c.coll.FindOneAndUpdate(ctx, cfg.filter, u.update, opts).Decode(&single); err != nil {
+		return nil, c.wrapError(err)
+	}
+	return c.decode(ctx, single)
+}
+
+func (c *Collection[T]) FindOneAndReplace(ctx context.Context, data T, query ...QueryOptions) (*T, error) {
+	cfg, err := c.query(query...)
+	if err != nil {
+		return nil, err
+	}
+	if err := runHook(ctx, &data, BeforeUpdater.BeforeUpdate); err != nil {
+		return nil, err
+	}
+	doc, pipeline, err := c.replacement(ctx, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	var res *mongo.SingleResult
+	if pipeline != nil {
+		opts := options.FindOneAndUpdate().SetUpsert(cfg.upsert).SetReturnDocument(cfg.returnDocument())
+		if len(cfg.order) > 0 {
+			opts.SetSort(cfg.order)
 		}
+		res = c.coll.FindOneAndUpdate(ctx, cfg.filter, pipeline, opts)
+	} else {
+		opts := options.FindOneAndReplace().SetUpsert(cfg.upsert).SetReturnDocument(cfg.returnDocument())
+		if len(cfg.order) > 0 {
+			opts.SetSort(cfg.order)
+		}
+		res = c.coll.FindOneAndReplace(ctx, cfg.filter, doc, opts)
+	}
+	var single bson.D
+	if err := res.Decode(&single); err != nil {
+		return nil, c.wrapError(err)
 	}
-	_, err := c.coll.DeleteOne(ctx, cfg.filter)
-	return err
+	return c.decode(ctx, single)
 }
-func (c *Collection[T]) DeleteMany(ctx context.Context, query ...QueryOptions) error {
-	cfg := &querier{
-		filter: make(bson.D, 0),
-		order:  make(bson.D, 0),
-		limit:  0,
-		offset: 0,
+
+// FindOneAndDelete deletes the first document matching query, or marks it as
+// deleted when T embeds SoftDelete, and returns it. BeforeDelete is called on
+// the document before it is deleted, AfterDelete after.
+func (c *Collection[T]) FindOneAndDelete(ctx context.Context, query ...QueryOptions) (*T, error) {
+	cfg, err := c.query(query...)
+	if err != nil {
+		return nil, err
+	}
+	return c.findAndRemove(ctx, cfg)
+}
+
+func (c *Collection[T]) findAndRemove(ctx context.Context, cfg *querier) (*T, error) {
+	filter := cfg.filter
+	if _, ok := any(new(T)).(BeforeDeleter); ok {
+		// Load the document for the hook, then delete exactly that one.
+		var target bson.D
+		opts := options.FindOne()
+		if len(cfg.order) > 0 {
+			opts.SetSort(cfg.order)
+		}
+		if err := c.coll.FindOne(ctx, filter, opts).Decode(&target); err != nil {
+			return nil, c.wrapError(err)
+		}
+		data, err := c.unMarshal(target)
+		if err != nil {
+			return nil, err
+		}
+		if err := runHook(ctx, data, BeforeDeleter.BeforeDelete); err != nil {
+			return nil, err
+		}
+		filter = bson.D{{Key: "$and", Value: bson.A{filter, bson.D{{Key: "_id", Value: lookupPath(target, "_id")}}}}}
+	}
+
+	var single bson.D
+	if f, err := c.deletedAtField(); err != nil {
+		return nil, err
+	} else if f != nil {
+		opts := options.FindOneAndUpdate()
+		if len(cfg.order) > 0 {
+			opts.SetSort(cfg.order)
+		}
+		if err := c.coll.FindOneAndUpdate(ctx, filter, c.softDeleteUpdate(f), opts).Decode(&single); err != nil {
+			return nil, c.wrapError(err)
+		}
+	} else {
+		opts := options.FindOneAndDelete()
+		if len(cfg.order) > 0 {
+			opts.SetSort(cfg.order)
+		}
+		if err := c.coll.FindOneAndDelete(ctx, filter, opts).Decode(&single); err != nil {
+			return nil, c.wrapError(err)
+		}
+	}
+	res, err := c.decode(ctx, single)
+	if err != nil {
+		return nil, err
+	}
+	if err := runHook(ctx, res, AfterDeleter.AfterDelete); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// DeleteOne deletes the first document matching query, or marks it as deleted
+// when T embeds SoftDelete. When T has delete hooks, the document is loaded
+// and passed to them.
+func (c *Collection[T]) DeleteOne(ctx context.Context, query ...QueryOptions) (*Result, error) {
+	cfg, err := c.query(query...)
+	if err != nil {
+		return nil, err
+	}
+	if !hasDeleteHooks[T]() {
+		return c.remove(ctx, cfg.filter, false)
+	}
+
+	if _, err := c.findAndRemove(ctx, cfg); errors.Is(err, ErrNotFound) {
+		return &Result{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	res := &Result{DeletedCount: 1}
+	if f, err := c.deletedAtField(); err == nil && f != nil {
+		res.MatchedCount, res.ModifiedCount = 1, 1
+	}
+	return res, nil
+}
+
+// DeleteMany deletes the documents matching query, or marks them as deleted
+// when T embeds SoftDelete. When T has delete hooks, the documents are loaded
+// and passed to them; an error from BeforeDelete aborts the delete before any
+// document is removed.
+func (c *Collection[T]) DeleteMany(ctx context.Context, query ...QueryOptions) (*Result, error) {
+	cfg, err := c.query(query...)
+	if err != nil {
+		return nil, err
+	}
+	if !hasDeleteHooks[T]() {
+		return c.remove(ctx, cfg.filter, true)
+	}
+
+	docs, filter, err := c.beforeDelete(ctx, cfg.filter, 0)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.remove(ctx, filter, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.afterDelete(ctx, docs); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// beforeDelete loads the documents matching filter, at most limit when it is
+// positive, and calls BeforeDelete on each. The returned filter matches only
+// the loaded documents, so that none is deleted without its hooks.
+func (c *Collection[T]) beforeDelete(ctx context.Context, filter bson.D, limit int64) ([]*T, bson.D, error) {
+	opts := options.Find()
+	if limit > 0 {
+		opts.SetLimit(limit)
+	}
+	cur, err := c.coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, nil, c.wrapError(err)
 	}
-	for _, q := range query {
-		if err := q(cfg); err != nil {
+	defer cur.Close(ctx)
+
+	var (
+		docs []*T
+		ids  = bson.A{}
+	)
+	for cur.Next(ctx) {
+		var doc bson.D
+		if err := cur.Decode(&doc); err != nil {
+			return nil, nil, c.wrapError(err)
+		}
+		data, err := c.unMarshal(doc)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := runHook(ctx, data, BeforeDeleter.BeforeDelete); err != nil {
+			return nil, nil, err
+		}
+		docs = append(docs, data)
+		ids = append(ids, lookupPath(doc, "_id"))
+	}
+	if err := cur.Err(); err != nil {
+		return nil, nil, c.wrapError(err)
+	}
+	return docs, byIDs(filter, ids), nil
+}
+
+func (c *Collection[T]) afterDelete(ctx context.Context, docs []*T) error {
+	for _, data := range docs {
+		if err := runHook(ctx, data, AfterDeleter.AfterDelete); err != nil {
 			return err
 		}
 	}
-	_, err := c.coll.DeleteMany(ctx, cfg.filter)
-	return err
+	return nil
+}
+
+// byIDs narrows filter to the documents with the given ids.
+func byIDs(filter bson.D, ids bson.A) bson.D {
+	return bson.D{{Key: "$and", Value: bson.A{filter, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}}}}}
+}
+
+func hasDeleteHooks[T any]() bool {
+	_, before := any(new(T)).(BeforeDeleter)
+	_, after := any(new(T)).(AfterDeleter)
+	return before || after
 }
 
 func (c *Collection[T]) Collection() *mongo.Collection {
 	return c.coll
 }
 
-func registerIndexes(coll *mongo.Collection, fields []*Field) error {
+func (c *Collection[T]) schema() (*Schema, error) {
+	var t T
+	return parse(t, c.cacheStore)
+}
+
+func (c *Collection[T]) wrapError(err error) error {
+	s, _ := c.schema()
+	return wrapError(err, s)
+}
+
+func registerIndexes(ctx context.Context, coll *mongo.Collection, indexes []IndexSpec) error {
 	var idx []mongo.IndexModel
-	for _, field := range fields {
-		if field.Index {
-			idx = append(idx, mongo.IndexModel{
-				Keys:    bson.D{{Key: field.DBName, Value: 1}},
-				Options: options.Index().SetUnique(true),
-			})
-		}
+	for _, spec := range indexes {
+		idx = append(idx, spec.model())
 	}
 
 	if len(idx) < 1 {
 		return nil
 	}
-	_, err := coll.Indexes().CreateMany(context.Background(), idx)
+	_, err := coll.Indexes().CreateMany(ctx, idx)
 	if err != nil {
 		return err
 	}
@@ -217,7 +629,10 @@ func registerIndexes(coll *mongo.Collection, fields []*Field) error {
 	return nil
 }
 
-func (c *Collection[T]) marshal(ctx context.Context, data any) (bson.D, error) {
+// marshal encodes data using the monarch field names of its schema. Fields
+// tagged omitempty are left out when zero, and with partial set every zero
+// field is left out.
+func (c *Collection[T]) marshal(ctx context.Context, data any, partial bool) (bson.D, error) {
 	schema, err := parse(data, c.cacheStore)
 	if err != nil {
 		return nil, err
@@ -226,6 +641,9 @@ func (c *Collection[T]) marshal(ctx context.Context, data any) (bson.D, error) {
 	value := reflect.ValueOf(data)
 	for _, f := range schema.Fields {
 		v := f.ReflectValueOf(ctx, value)
+		if (partial || f.OmitEmpty) && v.IsZero() {
+			continue
+		}
 
 		switch f.FieldType.Kind() {
 		case reflect.Struct:
@@ -237,15 +655,15 @@ func (c *Collection[T]) marshal(ctx context.Context, data any) (bson.D, error) {
 				}
 				doc = append(doc, bson.E{Key: f.DBName, Value: fdata})
 			default:
-				fdata, err := c.marshal(ctx, v.Interface())
+				fdata, err := c.marshal(ctx, v.Interface(), partial)
 				if err != nil {
 					return nil, err
 				}
 				doc = append(doc, bson.E{Key: f.DBName, Value: fdata})
 			}
 		case reflect.Slice, reflect.Array:
-			switch v.Type().Elem().Kind() {
-			case reflect.Struct:
+			switch et := v.Type().Elem(); {
+			case et.Kind() == reflect.Struct || isStructPointer(et):
 				var arr bson.A
 				for i := range v.Len() {
 					elem := v.Index(i)
@@ -257,7 +675,14 @@ func (c *Collection[T]) marshal(ctx context.Context, data any) (bson.D, error) {
 							return nil, err
 						}
 					default:
-						fdata, err = c.marshal(ctx, elem.Interface())
+						if elem.Kind() == reflect.Pointer {
+							if elem.IsNil() {
+								arr = append(arr, nil)
+								continue
+							}
+							elem = elem.Elem()
+						}
+						fdata, err = c.marshal(ctx, elem.Interface(), false)
 						if err != nil {
 							return nil, err
 						}
@@ -272,6 +697,17 @@ func (c *Collection[T]) marshal(ctx context.Context, data any) (bson.D, error) {
 				}
 				doc = append(doc, bson.E{Key: f.DBName, Value: fdata})
 			}
+		case reflect.Pointer:
+			var fdata any
+			if isStructPointer(f.FieldType) && !v.IsNil() {
+				fdata, err = c.marshal(ctx, v.Elem().Interface(), partial)
+			} else {
+				fdata, err = c.encodeValue(v)
+			}
+			if err != nil {
+				return nil, err
+			}
+			doc = append(doc, bson.E{Key: f.DBName, Value: fdata})
 		case reflect.Map:
 			fdata, err := c.encodeValue(v)
 			if err != nil {
@@ -291,6 +727,65 @@ func (c *Collection[T]) marshal(ctx context.Context, data any) (bson.D, error) {
 	return doc, nil
 }
 
+// isStructPointer reports whether t points to a struct that marshal encodes
+// with its monarch field names.
+func isStructPointer(t reflect.Type) bool {
+	return t.Kind() == reflect.Pointer && t.Elem().Kind() == reflect.Struct && t.Elem() != tTime
+}
+
+// updateDoc builds the update document for UpdateOne, UpdateMany and Upsert.
+// Partial updates address nested fields by dotted path so that fields left out
+// of data keep their stored value. autoCreateTime fields are never set by an
+// update; an upsert writes them with $setOnInsert.
+func (c *Collection[T]) updateDoc(ctx context.Context, data *T, partial, upsert bool) (bson.D, error) {
+	if err := c.touch(ctx, data, upsert); err != nil {
+		return nil, err
+	}
+	val, err := c.marshal(ctx, *data, partial)
+	if err != nil {
+		return nil, err
+	}
+	if partial {
+		val = flatten("", val)
+	}
+	s, err := c.schema()
+	if err != nil {
+		return nil, err
+	}
+	set, onInsert := make(bson.D, 0, len(val)), make(bson.D, 0)
+	for _, e := range val {
+		if f, ok := s.FieldByDBName[e.Key]; ok && f.AutoCreateTime {
+			onInsert = append(onInsert, e)
+			continue
+		}
+		set = append(set, e)
+	}
+	if len(set) == 0 {
+		return nil, errors.New("error, no fields to update")
+	}
+	update := bson.D{{Key: "$set", Value: set}}
+	if upsert && len(onInsert) > 0 {
+		update = append(update, bson.E{Key: "$setOnInsert", Value: onInsert})
+	}
+	return update, nil
+}
+
+func flatten(prefix string, doc bson.D) bson.D {
+	out := make(bson.D, 0, len(doc))
+	for _, e := range doc {
+		key := e.Key
+		if prefix != "" {
+			key = prefix + "." + e.Key
+		}
+		if sub, ok := e.Value.(bson.D); ok && len(sub) > 0 && !isOperatorDoc(sub) {
+			out = append(out, flatten(key, sub)...)
+			continue
+		}
+		out = append(out, bson.E{Key: key, Value: e.Value})
+	}
+	return out
+}
+
 func (c *Collection[T]) encodeValue(v reflect.Value) (interface{}, error) {
 	switch v.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
@@ -308,40 +803,95 @@ func (c *Collection[T]) encodeValue(v reflect.Value) (interface{}, error) {
 	case reflect.Bool:
 		return v.Bool(), nil
 	case reflect.Pointer:
+		if v.IsNil() {
+			return nil, nil
+		}
 		return c.encodeValue(v.Elem())
 	default:
 		return v.Interface(), nil
 	}
 }
 
+// decode unmarshals a document read from the collection and runs the
+// AfterFind hook on it.
+func (c *Collection[T]) decode(ctx context.Context, doc bson.D) (*T, error) {
+	res, err := c.unMarshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	if err := runHook(ctx, res, AfterFinder.AfterFind); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
 func (c *Collection[T]) unMarshal(doc bson.D) (*T, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
 	defer cancel()
 
 	var result T
-	s, err := parse(result, c.cacheStore)
+	v, err := decodeDocument(ctx, doc, reflect.TypeOf(result), c.cacheStore)
 	if err != nil {
 		return nil, err
 	}
-	r := reflect.ValueOf(&result)
+
+	return v.Interface().(*T), nil
+}
+
+// decodeDocument decodes doc into a new value of the struct type t using the
+// monarch field names of its schema, and returns a pointer to it.
+func decodeDocument(ctx context.Context, doc bson.D, t reflect.Type, c *sync.Map) (reflect.Value, error) {
+	r := reflect.New(t)
+	s, err := parse(r.Interface(), c)
+	if err != nil {
+		return reflect.Value{}, err
+	}
 	for _, e := range doc {
-		if err := decodeValue(ctx, r, e, s.Fields, c.cacheStore); err != nil {
-			return nil, err
+		if err := decodeValue(ctx, r, e, s.Fields, c); err != nil {
+			return reflect.Value{}, err
 		}
 	}
-
-	return &result, nil
+	return r, nil
 }
 
 func decodeValue(ctx context.Context, r reflect.Value, e bson.E, fields []*Field, c *sync.Map) error {
 	for _, f := range fields {
 		if e.Key == f.DBName {
+			if f.FieldType.Kind() == reflect.Pointer && e.Value != nil {
+				// Decode into a new element through a copy of the field
+				// that addresses it, then point the field at it.
+				elem := reflect.New(f.FieldType.Elem())
+				ef := *f
+				ef.FieldType = f.FieldType.Elem()
+				ef.ReflectValueOf = func(context.Context, reflect.Value) reflect.Value { return elem.Elem() }
+				if err := decodeValue(ctx, r, e, []*Field{&ef}, c); err != nil {
+					return err
+				}
+				f.ReflectValueOf(ctx, r).Set(elem)
+				continue
+			}
 			switch e.Value.(type) {
+			case nil:
+				// nil pointers, slices and maps are stored as null.
+				f.ReflectValueOf(ctx, r).Set(reflect.Zero(f.FieldType))
 			case bson.A:
 				val := e.Value.(bson.A)
 				newVal := reflect.MakeSlice(f.FieldType, 0, 0)
-				switch f.FieldType.Elem().Kind() {
-				case reflect.Struct:
+				switch et := f.FieldType.Elem(); {
+				case isStructPointer(et):
+					for _, v := range val {
+						d, ok := v.(bson.D)
+						if !ok {
+							newVal = reflect.Append(newVal, reflect.Zero(et))
+							continue
+						}
+						t, err := decodeDocument(ctx, d, et.Elem(), c)
+						if err != nil {
+							return err
+						}
+						newVal = reflect.Append(newVal, t)
+					}
+				case et.Kind() == reflect.Struct:
 					for _, v := range val {
 						t := reflect.New(f.FieldType.Elem())
 						h, err := parse(t.Interface(), c)
@@ -362,7 +912,7 @@ func decodeValue(ctx context.Context, r reflect.Value, e bson.E, fields []*Field
 						}
 						newVal = reflect.Append(newVal, t.Elem())
 					}
-				case reflect.Map:
+				case et.Kind() == reflect.Map:
 					fmt.Println("why")
 				default:
 					for _, v := range val {
@@ -0,0 +1,129 @@
+package monarch
+
+import (
+	"context"
+	"iter"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// Cursor streams the documents of a query one at a time instead of loading
+// them all like FindMany. With Preload, the documents of each server batch are
+// decoded together so that their relations are loaded with one query per
+// batch.
+type Cursor[T any] struct {
+	c       *Collection[T]
+	cur     *mongo.Cursor
+	preload []string
+
+	batch    []*T
+	pos      int
+	batchErr error
+}
+
+func (c *Collection[T]) FindCursor(ctx context.Context, query ...QueryOptions) (*Cursor[T], error) {
+	cfg, err := c.query(query...)
+	if err != nil {
+		return nil, err
+	}
+
+	cur, err := c.coll.Find(ctx, cfg.filter, cfg.findOptions())
+	if err != nil {
+		return nil, c.wrapError(err)
+	}
+	return &Cursor[T]{c: c, cur: cur, preload: cfg.preload}, nil
+}
+
+func (cur *Cursor[T]) Next(ctx context.Context) bool {
+	if len(cur.preload) == 0 {
+		return cur.cur.Next(ctx)
+	}
+	if cur.batchErr == nil && cur.pos+1 < len(cur.batch) {
+		cur.pos++
+		return true
+	}
+	if !cur.cur.Next(ctx) {
+		return false
+	}
+	cur.batch, cur.pos = cur.batch[:0], 0
+	cur.batchErr = cur.loadBatch(ctx)
+	return true
+}
+
+// loadBatch decodes the current document and the rest of its server batch,
+// then preloads their relations.
+func (cur *Cursor[T]) loadBatch(ctx context.Context) error {
+	for {
+		var doc bson.D
+		if err := cur.cur.Decode(&doc); err != nil {
+			return cur.c.wrapError(err)
+		}
+		res, err := cur.c.decode(ctx, doc)
+		if err != nil {
+			return err
+		}
+		cur.batch = append(cur.batch, res)
+		if cur.cur.RemainingBatchLength() == 0 || !cur.cur.Next(ctx) {
+			break
+		}
+	}
+	return cur.c.preload(ctx, cur.batch, cur.preload)
+}
+
+// Decode decodes the current document.
+func (cur *Cursor[T]) Decode(ctx context.Context) (*T, error) {
+	if len(cur.preload) > 0 {
+		if cur.batchErr != nil {
+			return nil, cur.batchErr
+		}
+		return cur.batch[cur.pos], nil
+	}
+	var doc bson.D
+	if err := cur.cur.Decode(&doc); err != nil {
+		return nil, cur.c.wrapError(err)
+	}
+	return cur.c.decode(ctx, doc)
+}
+
+func (cur *Cursor[T]) Err() error {
+	return cur.c.wrapError(cur.cur.Err())
+}
+
+func (cur *Cursor[T]) Close(ctx context.Context) error {
+	return cur.cur.Close(ctx)
+}
+
+// Iter returns the documents matching query as a sequence for range-over-func
+// loops. The cursor is closed when the loop ends; an error ends the sequence.
+//
+//	for user, err := range users.Iter(ctx, monarch.BatchSize(500)) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func (c *Collection[T]) Iter(ctx context.Context, query ...QueryOptions) iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		cur, err := c.FindCursor(ctx, query...)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer cur.Close(context.WithoutCancel(ctx))
+
+		for cur.Next(ctx) {
+			res, err := cur.Decode(ctx)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(res, nil) {
+				return
+			}
+		}
+		if err := cur.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package monarch
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+var (
+	ErrNotFound      = errors.New("document not found")
+	ErrDuplicateKey  = errors.New("duplicate key")
+	ErrInvalidSchema = errors.New("invalid schema")
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+var dupKeyPattern = regexp.MustCompile(`index: (\S+) dup key: \{ ?"?([^":\s]*)"?\s*:`)
+
+// DuplicateKeyError is returned when a write violates a unique index. Field is
+// the schema field of the first key of the index, or nil when the key is not
+// part of the schema.
+type DuplicateKeyError struct {
+	Index string
+	Keys  []string
+	Field *Field
+	err   error
+}
+
+func (e *DuplicateKeyError) Error() string {
+	if e.Field != nil {
+		return fmt.Sprintf("duplicate key on field %s (%s): %v", e.Field.Name, e.Field.DBName, e.err)
+	}
+	return fmt.Sprintf("duplicate key: %v", e.err)
+}
+
+func (e *DuplicateKeyError) Is(target error) bool {
+	return target == ErrDuplicateKey
+}
+
+func (e *DuplicateKeyError) Unwrap() error {
+	return e.err
+}
+
+// wrapError maps driver errors to the monarch errors. The driver error stays
+// in the chain so errors.Is(err, mongo.ErrNoDocuments) keeps working.
+func wrapError(err error, s *Schema) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	case mongo.IsDuplicateKeyError(err):
+		var we mongo.WriteException
+		if errors.As(err, &we) {
+			for _, e := range we.WriteErrors {
+				if isDuplicateKeyCode(e.Code) {
+					return duplicateKeyError(e.Message, e.Raw, err, s)
+				}
+			}
+		}
+		var bwe mongo.BulkWriteException
+		if errors.As(err, &bwe) {
+			for _, e := range bwe.WriteErrors {
+				if isDuplicateKeyCode(e.Code) {
+					return duplicateKeyError(e.Message, e.Raw, err, s)
+				}
+			}
+		}
+		var ce mongo.CommandError
+		if errors.As(err, &ce) {
+			return duplicateKeyError(ce.Message, ce.Raw, err, s)
+		}
+		return duplicateKeyError(err.Error(), nil, err, s)
+	default:
+		return err
+	}
+}
+
+func isDuplicateKeyCode(code int) bool {
+	return code == 11000 || code == 11001 || code == 12582
+}
+
+// duplicateKeyError reads the conflicting keys from the keyPattern the server
+// reports, falling back to the E11000 message for older servers.
+func duplicateKeyError(msg string, raw bson.Raw, err error, s *Schema) *DuplicateKeyError {
+	dup := &DuplicateKeyError{err: err}
+	if raw != nil {
+		if kp, ok := raw.Lookup("keyPattern").DocumentOK(); ok {
+			if elems, err := kp.Elements(); err == nil {
+				for _, e := range elems {
+					dup.Keys = append(dup.Keys, e.Key())
+				}
+			}
+		}
+	}
+	if m := dupKeyPattern.FindStringSubmatch(msg); m != nil {
+		dup.Index = m[1]
+		// older servers leave the key names out: { : "value" }
+		if len(dup.Keys) == 0 && m[2] != "" {
+			dup.Keys = []string{m[2]}
+		}
+	}
+	if s != nil && len(dup.Keys) > 0 {
+		dup.Field = s.FieldByDBName[dup.Keys[0]]
+	}
+	return dup
+}
+
+// BulkError is returned by SaveMany and BulkWriter.Flush when some writes
+// failed. Each WriteError carries the position of the failed item in the
+// input, and its error is mapped like the single document writes, so a
+// duplicate key is a *DuplicateKeyError.
+type BulkError struct {
+	WriteErrors []WriteError
+}
+
+type WriteError struct {
+	Index int
+	Err   error
+}
+
+func (e *BulkError) Error() string {
+	msgs := make([]string, 0, len(e.WriteErrors))
+	for _, we := range e.WriteErrors {
+		msgs = append(msgs, fmt.Sprintf("item %d: %v", we.Index, we.Err))
+	}
+	return fmt.Sprintf("%d bulk write errors: %s", len(e.WriteErrors), strings.Join(msgs, "; "))
+}
+
+func (e *BulkError) Unwrap() []error {
+	errs := make([]error, 0, len(e.WriteErrors))
+	for _, we := range e.WriteErrors {
+		errs = append(errs, we.Err)
+	}
+	return errs
+}
+
+// bulkWriteErrors maps the write errors of a bulk operation to the input
+// positions, offset being the position of the first write sent. It returns
+// false when err is not a per-write failure.
+func bulkWriteErrors(err error, offset int, s *Schema) ([]WriteError, bool) {
+	var bwe mongo.BulkWriteException
+	if !errors.As(err, &bwe) || len(bwe.WriteErrors) == 0 {
+		return nil, false
+	}
+	errs := make([]WriteError, 0, len(bwe.WriteErrors))
+	for _, we := range bwe.WriteErrors {
+		var itemErr error = we.WriteError
+		if isDuplicateKeyCode(we.Code) {
+			itemErr = duplicateKeyError(we.Message, we.Raw, we.WriteError, s)
+		}
+		errs = append(errs, WriteError{Index: offset + we.Index, Err: itemErr})
+	}
+	return errs, true
+}
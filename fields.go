@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"reflect"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
 )
@@ -21,6 +22,18 @@ type Field struct {
 	Schema            *Schema
 	EmbeddedSchema    *Schema
 	Index             bool
+	IndexOptions      []IndexOption
+	OmitEmpty         bool
+	AutoCreateTime    bool
+	AutoUpdateTime    bool
+	SoftDelete        bool
+	Ref               string
+	RefKey            string
+	Rel               string
+	Required          bool
+	Enum              []string
+	Min               *float64
+	Max               *float64
 	ReflectValueOf    func(ctx context.Context, val reflect.Value) reflect.Value
 }
 
@@ -28,7 +41,8 @@ func (schema *Schema) parseField(fieldStruct reflect.StructField) *Field {
 	var (
 		tags = parseTagSetting(fieldStruct.Tag.Get("monarch"), ",")
 	)
-	if CheckSkip(tags) {
+	rel, _ := lookupTag(tags, "rel")
+	if CheckSkip(tags) && rel == "" {
 		return nil
 	}
 
@@ -41,6 +55,49 @@ func (schema *Schema) parseField(fieldStruct reflect.StructField) *Field {
 		Schema:            schema,
 		StructField:       fieldStruct,
 		Index:             CheckIndex(tags),
+		OmitEmpty:         CheckOmitEmpty(tags) || slices.Contains(tags, "softDelete"),
+		AutoCreateTime:    slices.Contains(tags, "autoCreateTime"),
+		AutoUpdateTime:    slices.Contains(tags, "autoUpdateTime"),
+		SoftDelete:        slices.Contains(tags, "softDelete"),
+		Rel:               rel,
+		Required:          slices.Contains(tags, "required"),
+	}
+
+	if enum, ok := lookupTag(tags, "enum"); ok {
+		field.Enum = strings.Split(enum, "|")
+	}
+	for _, bound := range []struct {
+		key  string
+		dest **float64
+	}{{"min", &field.Min}, {"max", &field.Max}} {
+		if v, ok := lookupTag(tags, bound.key); ok {
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				schema.err = fmt.Errorf("%w: field %s: invalid %s %q", ErrInvalidSchema, field.Name, bound.key, v)
+				continue
+			}
+			*bound.dest = &n
+		}
+	}
+
+	if ref, ok := lookupTag(tags, "ref"); ok {
+		// ref=users matches the id field of users, ref=users.email its email
+		field.Ref, field.RefKey, _ = strings.Cut(ref, ".")
+		if field.RefKey == "" {
+			field.RefKey = "id"
+		}
+	}
+
+	if field.Index {
+		field.IndexOptions = append(field.IndexOptions, IndexOption{Unique: true})
+	}
+	if tag, ok := fieldStruct.Tag.Lookup("index"); ok {
+		opts, err := parseIndexTag(tag)
+		if err != nil {
+			schema.err = fmt.Errorf("%w: field %s: %w", ErrInvalidSchema, field.Name, err)
+		}
+		field.IndexOptions = append(field.IndexOptions, opts...)
+		field.Index = true
 	}
 
 	fieldValue := reflect.New(field.IndirectFieldType)
@@ -73,7 +130,7 @@ func (schema *Schema) parseField(fieldStruct reflect.StructField) *Field {
 			}
 		case reflect.Invalid, reflect.Uintptr, reflect.Array, reflect.Chan, reflect.Func, reflect.Interface,
 			reflect.Map, reflect.Ptr, reflect.Slice, reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
-			schema.err = fmt.Errorf("invalid embedded struct for %s's field %s, should be struct, but got %v", field.Schema.Name, field.Name, field.FieldType)
+			schema.err = fmt.Errorf("%w: invalid embedded struct for %s's field %s, should be struct, but got %v", ErrInvalidSchema, field.Schema.Name, field.Name, field.FieldType)
 		}
 	}
 
@@ -118,9 +175,20 @@ func (field *Field) setupValuerAndSetter() {
 func parseTagSetting(tag, seperator string) []string {
 	return strings.Split(tag, seperator)
 }
+func lookupTag(tag []string, key string) (string, bool) {
+	for _, t := range tag {
+		if v, ok := strings.CutPrefix(t, key+"="); ok {
+			return v, true
+		}
+	}
+	return "", false
+}
 func CheckIndex(tag []string) bool {
 	return slices.Contains(tag, "index")
 }
+func CheckOmitEmpty(tag []string) bool {
+	return slices.Contains(tag, "omitempty")
+}
 func CheckSkip(tag []string) bool {
 	return slices.Contains(tag, "-")
 }
@@ -0,0 +1,42 @@
+package monarch
+
+import "context"
+
+// Hooks are optional interfaces on the model type T. Collection calls them
+// around the matching driver operation and aborts the operation when a hook
+// returns an error. Hooks on writes are called on a pointer to the data passed
+// in, so changes made by BeforeSave and BeforeUpdate are persisted. Delete
+// hooks are called on each deleted document, which the delete methods load
+// first when T implements them; only the loaded documents are deleted.
+type (
+	BeforeSaver interface {
+		BeforeSave(ctx context.Context) error
+	}
+	AfterSaver interface {
+		AfterSave(ctx context.Context) error
+	}
+	BeforeUpdater interface {
+		BeforeUpdate(ctx context.Context) error
+	}
+	AfterUpdater interface {
+		AfterUpdate(ctx context.Context) error
+	}
+	AfterFinder interface {
+		AfterFind(ctx context.Context) error
+	}
+	BeforeDeleter interface {
+		BeforeDelete(ctx context.Context) error
+	}
+	AfterDeleter interface {
+		AfterDelete(ctx context.Context) error
+	}
+)
+
+// runHook calls hook when v implements H, e.g.
+// runHook(ctx, &data, BeforeSaver.BeforeSave).
+func runHook[H any](ctx context.Context, v any, hook func(H, context.Context) error) error {
+	if h, ok := v.(H); ok {
+		return hook(h, ctx)
+	}
+	return nil
+}
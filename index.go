@@ -0,0 +1,213 @@
+package monarch
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// IndexOption is one index a field takes part in, declared with the index
+// struct tag:
+//
+//	Email    string `monarch:"email" index:"name=email_tenant,order=1,unique"`
+//	TenantID string `monarch:"tenant_id" index:"name=email_tenant,order=2"`
+//	Expires  time.Time `monarch:"expires" index:"ttl=24h"`
+//
+// Fields sharing a name form a compound index, sorted by order. Several
+// indexes on one field are separated by ";". The legacy index flag of the
+// monarch tag declares a unique ascending index.
+type IndexOption struct {
+	Name    string
+	Order   int
+	Desc    bool
+	Type    string
+	Unique  bool
+	Sparse  bool
+	Partial bool
+	TTL     time.Duration
+}
+
+type IndexKey struct {
+	Field string
+	Value any
+}
+
+// IndexSpec is a complete index of a collection. Partial is the
+// partialFilterExpression and cannot be combined with Sparse; TTL is only
+// honoured on single field indexes.
+type IndexSpec struct {
+	Name    string
+	Keys    []IndexKey
+	Unique  bool
+	Sparse  bool
+	TTL     time.Duration
+	Partial bson.D
+}
+
+// Indexer can be implemented by a model to declare indexes that the struct
+// tags cannot express. They are added to the indexes from the tags.
+type Indexer interface {
+	Indexes() []IndexSpec
+}
+
+func parseIndexTag(tag string) ([]IndexOption, error) {
+	var opts []IndexOption
+	for _, decl := range strings.Split(tag, ";") {
+		opt := IndexOption{}
+		for _, setting := range strings.Split(decl, ",") {
+			key, value, _ := strings.Cut(strings.TrimSpace(setting), "=")
+			switch key {
+			case "":
+			case "name":
+				opt.Name = value
+			case "order":
+				n, err := strconv.Atoi(value)
+				if err != nil {
+					return nil, fmt.Errorf("invalid index order %q", value)
+				}
+				opt.Order = n
+			case "desc":
+				opt.Desc = true
+			case "type":
+				switch value {
+				case "text", "2dsphere", "2d", "hashed":
+					opt.Type = value
+				default:
+					return nil, fmt.Errorf("unsupported index type %q", value)
+				}
+			case "unique":
+				opt.Unique = true
+			case "sparse":
+				opt.Sparse = true
+			case "partial":
+				opt.Partial = true
+			case "ttl":
+				d, err := time.ParseDuration(value)
+				if err != nil {
+					return nil, fmt.Errorf("invalid index ttl %q", value)
+				}
+				opt.TTL = d
+			default:
+				return nil, fmt.Errorf("unknown index setting %q", key)
+			}
+		}
+		opts = append(opts, opt)
+	}
+	return opts, nil
+}
+
+// buildIndexes groups the index options of the fields into index specs and
+// adds the ones returned by Indexes when the model implements Indexer.
+func (schema *Schema) buildIndexes() error {
+	type member struct {
+		field *Field
+		opt   IndexOption
+	}
+	var (
+		names  []string
+		groups = make(map[string][]member)
+	)
+	for _, field := range schema.Fields {
+		for _, opt := range field.IndexOptions {
+			name := opt.Name
+			if name == "" {
+				// unnamed indexes are single field, keep them apart
+				name = "\x00" + field.DBName + strconv.Itoa(len(names))
+			}
+			if _, ok := groups[name]; !ok {
+				names = append(names, name)
+			}
+			groups[name] = append(groups[name], member{field: field, opt: opt})
+		}
+	}
+
+	for _, name := range names {
+		members := groups[name]
+		slices.SortStableFunc(members, func(a, b member) int { return a.opt.Order - b.opt.Order })
+
+		spec := IndexSpec{}
+		if !strings.HasPrefix(name, "\x00") {
+			spec.Name = name
+		}
+		for _, m := range members {
+			var value any = int32(1)
+			switch {
+			case m.opt.Type != "":
+				value = m.opt.Type
+			case m.opt.Desc:
+				value = int32(-1)
+			}
+			spec.Keys = append(spec.Keys, IndexKey{Field: m.field.DBName, Value: value})
+			spec.Unique = spec.Unique || m.opt.Unique
+			spec.Sparse = spec.Sparse || m.opt.Sparse
+			if m.opt.Partial {
+				spec.Partial = append(spec.Partial, bson.E{Key: m.field.DBName, Value: bson.D{{Key: "$exists", Value: true}}})
+			}
+			if m.opt.TTL > 0 {
+				if len(members) > 1 {
+					return fmt.Errorf("%w: ttl on compound index %s", ErrInvalidSchema, name)
+				}
+				spec.TTL = m.opt.TTL
+			}
+		}
+		schema.Indexes = append(schema.Indexes, spec)
+	}
+
+	if indexer, ok := reflect.New(schema.SchemaType).Interface().(Indexer); ok {
+		schema.Indexes = append(schema.Indexes, indexer.Indexes()...)
+	}
+	for i := range schema.Indexes {
+		if len(schema.Indexes[i].Keys) == 0 {
+			return fmt.Errorf("%w: index %s has no keys", ErrInvalidSchema, schema.Indexes[i].Name)
+		}
+		if schema.Indexes[i].Name == "" {
+			schema.Indexes[i].Name = schema.Indexes[i].defaultName()
+		}
+		// the server rejects sparse indexes with a partialFilterExpression
+		if schema.Indexes[i].Sparse && len(schema.Indexes[i].Partial) > 0 {
+			return fmt.Errorf("%w: index %s is both sparse and partial", ErrInvalidSchema, schema.Indexes[i].Name)
+		}
+	}
+	return nil
+}
+
+// defaultName is the name the server gives an index created without one.
+func (spec IndexSpec) defaultName() string {
+	parts := make([]string, 0, len(spec.Keys)*2)
+	for _, k := range spec.Keys {
+		parts = append(parts, k.Field, fmt.Sprint(k.Value))
+	}
+	return strings.Join(parts, "_")
+}
+
+func (spec IndexSpec) keys() bson.D {
+	keys := make(bson.D, 0, len(spec.Keys))
+	for _, k := range spec.Keys {
+		keys = append(keys, bson.E{Key: k.Field, Value: k.Value})
+	}
+	return keys
+}
+
+func (spec IndexSpec) model() mongo.IndexModel {
+	opts := options.Index().SetName(spec.Name)
+	if spec.Unique {
+		opts.SetUnique(true)
+	}
+	if spec.Sparse {
+		opts.SetSparse(true)
+	}
+	if spec.TTL > 0 {
+		opts.SetExpireAfterSeconds(int32(spec.TTL / time.Second))
+	}
+	if len(spec.Partial) > 0 {
+		opts.SetPartialFilterExpression(spec.Partial)
+	}
+	return mongo.IndexModel{Keys: spec.keys(), Options: opts}
+}
@@ -0,0 +1,224 @@
+package monarch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type IndexAction string
+
+const (
+	IndexCreate IndexAction = "create"
+	IndexDrop   IndexAction = "drop"
+	IndexModify IndexAction = "modify"
+)
+
+// IndexChange is one step of an index plan. Modify drops the live index and
+// creates the declared one, since the server cannot change index options in
+// place.
+type IndexChange struct {
+	Collection string
+	Action     IndexAction
+	Name       string
+	Declared   *IndexSpec
+	Live       *IndexSpec
+}
+
+func (ch IndexChange) String() string {
+	return fmt.Sprintf("%s %s.%s", ch.Action, ch.Collection, ch.Name)
+}
+
+type IndexPlan struct {
+	Changes []IndexChange
+}
+
+type SyncOptions struct {
+	// Apply executes the plan; without it SyncIndexes is a dry run.
+	Apply bool
+	// KeepUnknown leaves live indexes that no schema declares in place.
+	KeepUnknown bool
+	// Collections limits the sync to these collections, all registered
+	// collections when empty.
+	Collections []string
+}
+
+type liveIndex struct {
+	Name               string `bson:"name"`
+	Key                bson.D `bson:"key"`
+	Unique             bool   `bson:"unique"`
+	Sparse             bool   `bson:"sparse"`
+	ExpireAfterSeconds *int64 `bson:"expireAfterSeconds"`
+	Partial            bson.D `bson:"partialFilterExpression"`
+	Weights            bson.D `bson:"weights"`
+}
+
+// SyncIndexes compares the indexes declared by the registered schemas with
+// the live ones and returns the plan to reconcile them, applying it when
+// opts.Apply is set.
+func (m *Monarch) SyncIndexes(ctx context.Context, opts SyncOptions) (*IndexPlan, error) {
+	plan := &IndexPlan{}
+	for _, s := range m.Schemas() {
+		if len(opts.Collections) > 0 && !slices.Contains(opts.Collections, s.Collection) {
+			continue
+		}
+		coll := m.db.Collection(s.Collection)
+		changes, err := planIndexes(ctx, coll, s, opts.KeepUnknown)
+		if err != nil {
+			return nil, err
+		}
+		plan.Changes = append(plan.Changes, changes...)
+	}
+
+	if !opts.Apply {
+		return plan, nil
+	}
+	if err := plan.apply(ctx, m.db); err != nil {
+		return plan, err
+	}
+	return plan, nil
+}
+
+func planIndexes(ctx context.Context, coll *mongo.Collection, s *Schema, keepUnknown bool) ([]IndexChange, error) {
+	live, err := listIndexes(ctx, coll)
+	if err != nil {
+		return nil, err
+	}
+	return diffIndexes(s, live, keepUnknown), nil
+}
+
+// diffIndexes returns the changes turning the live indexes into those
+// declared by s. Live indexes s does not declare are dropped, except _id_
+// and unless keepUnknown is set.
+func diffIndexes(s *Schema, live []IndexSpec, keepUnknown bool) []IndexChange {
+	var changes []IndexChange
+	matched := make(map[string]bool)
+	for i := range s.Indexes {
+		declared := &s.Indexes[i]
+		idx := slices.IndexFunc(live, func(l IndexSpec) bool { return l.Name == declared.Name })
+		if idx < 0 {
+			// same keys under another name would make the create fail
+			idx = slices.IndexFunc(live, func(l IndexSpec) bool { return sameKeys(l.Keys, declared.Keys) })
+		}
+		if idx < 0 {
+			changes = append(changes, IndexChange{Collection: s.Collection, Action: IndexCreate, Name: declared.Name, Declared: declared})
+			continue
+		}
+		current := &live[idx]
+		matched[current.Name] = true
+		if current.Name != declared.Name || !sameIndex(*current, *declared) {
+			changes = append(changes, IndexChange{Collection: s.Collection, Action: IndexModify, Name: declared.Name, Declared: declared, Live: current})
+		}
+	}
+	if keepUnknown {
+		return changes
+	}
+	for i := range live {
+		if live[i].Name == "_id_" || matched[live[i].Name] {
+			continue
+		}
+		changes = append(changes, IndexChange{Collection: s.Collection, Action: IndexDrop, Name: live[i].Name, Live: &live[i]})
+	}
+	return changes
+}
+
+// apply runs the drops before the creates so that a modified index never
+// coexists with its replacement.
+func (p *IndexPlan) apply(ctx context.Context, db *mongo.Database) error {
+	for _, ch := range p.Changes {
+		if ch.Action == IndexDrop || ch.Action == IndexModify {
+			if err := db.Collection(ch.Collection).Indexes().DropOne(ctx, ch.Live.Name); err != nil {
+				return fmt.Errorf("%s: %w", ch, err)
+			}
+		}
+	}
+	for _, ch := range p.Changes {
+		if ch.Action == IndexCreate || ch.Action == IndexModify {
+			if _, err := db.Collection(ch.Collection).Indexes().CreateOne(ctx, ch.Declared.model()); err != nil {
+				return fmt.Errorf("%s: %w", ch, err)
+			}
+		}
+	}
+	return nil
+}
+
+func listIndexes(ctx context.Context, coll *mongo.Collection) ([]IndexSpec, error) {
+	cur, err := coll.Indexes().List(ctx)
+	if err != nil {
+		// a collection that does not exist yet has no indexes
+		var ce mongo.CommandError
+		if errors.As(err, &ce) && ce.Name == "NamespaceNotFound" {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var specs []IndexSpec
+	for cur.Next(ctx) {
+		var l liveIndex
+		if err := cur.Decode(&l); err != nil {
+			return nil, err
+		}
+		spec := IndexSpec{Name: l.Name, Unique: l.Unique, Sparse: l.Sparse, Partial: l.Partial}
+		if l.ExpireAfterSeconds != nil {
+			spec.TTL = time.Duration(*l.ExpireAfterSeconds) * time.Second
+		}
+		for _, k := range l.Key {
+			switch k.Key {
+			case "_fts":
+				// text indexes list their fields under weights
+				for _, w := range l.Weights {
+					spec.Keys = append(spec.Keys, IndexKey{Field: w.Key, Value: "text"})
+				}
+			case "_ftsx":
+			default:
+				spec.Keys = append(spec.Keys, IndexKey{Field: k.Key, Value: k.Value})
+			}
+		}
+		specs = append(specs, spec)
+	}
+	return specs, cur.Err()
+}
+
+func sameIndex(a, b IndexSpec) bool {
+	return sameKeys(a.Keys, b.Keys) && a.Unique == b.Unique && a.Sparse == b.Sparse &&
+		a.TTL/time.Second == b.TTL/time.Second && sameDocument(a.Partial, b.Partial)
+}
+
+func sameKeys(a, b []IndexKey) bool {
+	return slices.EqualFunc(a, b, func(x, y IndexKey) bool {
+		return x.Field == y.Field && indexKeyValue(x.Value) == indexKeyValue(y.Value)
+	})
+}
+
+// indexKeyValue normalises the numeric types the server may return for key
+// directions, e.g. 1.0 for 1.
+func indexKeyValue(v any) string {
+	switch v := v.(type) {
+	case int32:
+		return fmt.Sprint(int64(v))
+	case int64:
+		return fmt.Sprint(v)
+	case int:
+		return fmt.Sprint(int64(v))
+	case float64:
+		return fmt.Sprint(int64(v))
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func sameDocument(a, b bson.D) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return len(a) == len(b)
+	}
+	ja, errA := bson.MarshalExtJSON(a, false, false)
+	jb, errB := bson.MarshalExtJSON(b, false, false)
+	return errA == nil && errB == nil && string(ja) == string(jb)
+}
@@ -0,0 +1,233 @@
+package monarch
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// JSONSchema returns the $jsonSchema validator matching how monarch stores
+// the schema. Fields tagged required must be present; enum=a|b, min= and max=
+// restrict their values, or lengths for strings and arrays.
+func (schema *Schema) JSONSchema() bson.D {
+	return schema.jsonSchema(map[reflect.Type]bool{})
+}
+
+func (schema *Schema) jsonSchema(seen map[reflect.Type]bool) bson.D {
+	seen[schema.SchemaType] = true
+	defer delete(seen, schema.SchemaType)
+
+	properties := bson.D{}
+	required := bson.A{}
+	for _, f := range schema.Fields {
+		if f.DBName == "" {
+			continue
+		}
+		// omitempty and DeletedAt fields may hold null, e.g. when an upsert
+		// copies a {deleted_at: null} condition into the inserted document
+		prop := schema.jsonType(f.FieldType, f.OmitEmpty || f.SoftDelete, seen)
+		prop = append(prop, f.jsonConstraints()...)
+		properties = append(properties, bson.E{Key: f.DBName, Value: prop})
+		if f.Required {
+			required = append(required, f.DBName)
+		}
+	}
+
+	doc := bson.D{{Key: "bsonType", Value: "object"}}
+	if len(required) > 0 {
+		doc = append(doc, bson.E{Key: "required", Value: required})
+	}
+	return append(doc, bson.E{Key: "properties", Value: properties})
+}
+
+// jsonType describes t as marshal encodes it: nil pointers, slices and maps
+// are stored as null. nullable allows null for any other type as well.
+func (schema *Schema) jsonType(t reflect.Type, nullable bool, seen map[reflect.Type]bool) bson.D {
+	for t.Kind() == reflect.Pointer {
+		t, nullable = t.Elem(), true
+	}
+
+	var (
+		bsonType any
+		extra    bson.D
+	)
+	switch {
+	case t == tTime:
+		bsonType = "date"
+	case t == tUUID:
+		bsonType = "binData"
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		bsonType, nullable = "binData", true
+	}
+	if bsonType == nil {
+		switch t.Kind() {
+		case reflect.Bool:
+			bsonType = "bool"
+		case reflect.String:
+			bsonType = "string"
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			bsonType = bson.A{"int", "long"}
+		case reflect.Float32, reflect.Float64:
+			bsonType = "double"
+		case reflect.Map:
+			bsonType, nullable = "object", true
+		case reflect.Slice, reflect.Array:
+			bsonType, nullable = "array", t.Kind() == reflect.Slice
+			extra = bson.D{{Key: "items", Value: schema.jsonType(t.Elem(), false, seen)}}
+		case reflect.Struct:
+			if seen[t] {
+				bsonType = "object"
+				break
+			}
+			nested, err := parse(reflect.New(t).Interface(), schema.cacheStore)
+			if err != nil {
+				bsonType = "object"
+				break
+			}
+			doc := nested.jsonSchema(seen)
+			if nullable {
+				doc[0].Value = bson.A{"object", "null"}
+			}
+			return doc
+		default:
+			return bson.D{}
+		}
+	}
+
+	if nullable {
+		if s, ok := bsonType.(string); ok {
+			bsonType = bson.A{s, "null"}
+		} else {
+			bsonType = append(bsonType.(bson.A), "null")
+		}
+	}
+	return append(bson.D{{Key: "bsonType", Value: bsonType}}, extra...)
+}
+
+func (f *Field) jsonConstraints() bson.D {
+	var doc bson.D
+	kind := f.IndirectFieldType.Kind()
+	if len(f.Enum) > 0 {
+		values := make(bson.A, 0, len(f.Enum))
+		for _, e := range f.Enum {
+			values = append(values, f.enumValue(e))
+		}
+		doc = append(doc, bson.E{Key: "enum", Value: values})
+	}
+
+	minKey, maxKey := "minimum", "maximum"
+	switch kind {
+	case reflect.String:
+		minKey, maxKey = "minLength", "maxLength"
+	case reflect.Slice, reflect.Array:
+		minKey, maxKey = "minItems", "maxItems"
+	}
+	for _, bound := range []struct {
+		key   string
+		value *float64
+	}{{minKey, f.Min}, {maxKey, f.Max}} {
+		if bound.value == nil {
+			continue
+		}
+		if minKey == "minimum" {
+			doc = append(doc, bson.E{Key: bound.key, Value: *bound.value})
+		} else {
+			doc = append(doc, bson.E{Key: bound.key, Value: int64(*bound.value)})
+		}
+	}
+	return doc
+}
+
+// enumValue converts an enum tag value to the type the field is stored as.
+func (f *Field) enumValue(v string) any {
+	switch f.IndirectFieldType.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	case reflect.Float32, reflect.Float64:
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return v
+}
+
+type registerConfig struct {
+	validator bool
+	level     string
+	action    string
+}
+
+type RegisterOptions func(*registerConfig) error
+
+// WithValidator makes RegisterCollection install the schema's JSONSchema as
+// the collection validator, creating the collection or updating an existing
+// one with collMod.
+func WithValidator() RegisterOptions {
+	return func(r *registerConfig) error {
+		r.validator = true
+		return nil
+	}
+}
+
+// ValidationLevel sets the validationLevel of the validator: "strict"
+// (default), "moderate" or "off".
+func ValidationLevel(level string) RegisterOptions {
+	return func(r *registerConfig) error {
+		switch level {
+		case "strict", "moderate", "off":
+			r.level = level
+			return nil
+		}
+		return errors.New("error, unrecognized validation level")
+	}
+}
+
+// ValidationAction sets the validationAction of the validator: "error"
+// (default) or "warn".
+func ValidationAction(action string) RegisterOptions {
+	return func(r *registerConfig) error {
+		switch action {
+		case "error", "warn":
+			r.action = action
+			return nil
+		}
+		return errors.New("error, unrecognized validation action")
+	}
+}
+
+func applyValidator(ctx context.Context, db *mongo.Database, s *Schema, cfg registerConfig) error {
+	validator := bson.D{{Key: "$jsonSchema", Value: s.JSONSchema()}}
+	level, action := cfg.level, cfg.action
+	if level == "" {
+		level = "strict"
+	}
+	if action == "" {
+		action = "error"
+	}
+
+	err := db.CreateCollection(ctx, s.Collection, options.CreateCollection().
+		SetValidator(validator).SetValidationLevel(level).SetValidationAction(action))
+	var ce mongo.CommandError
+	if !errors.As(err, &ce) || ce.Name != "NamespaceExists" {
+		return err
+	}
+	return db.RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: s.Collection},
+		{Key: "validator", Value: validator},
+		{Key: "validationLevel", Value: level},
+		{Key: "validationAction", Value: action},
+	}).Err()
+}
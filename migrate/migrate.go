@@ -0,0 +1,456 @@
+// Package migrate runs versioned migrations against a monarch database.
+//
+// Migrations are Go funcs registered under a positive version number. Applied
+// versions are recorded in the monarch_migrations collection, which also holds
+// the lock document that keeps concurrent runners apart. Each step runs in its
+// own transaction when the server supports them.
+package migrate
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-monarch/monarch"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+const (
+	DefaultCollection  = "monarch_migrations"
+	DefaultLockTimeout = 10 * time.Minute
+	MinLockTimeout     = time.Second
+	lockID             = "lock"
+)
+
+var (
+	ErrLocked         = errors.New("migrations locked by another runner")
+	ErrLockLost       = errors.New("migration lock taken over by another runner")
+	ErrIrreversible   = errors.New("migration has no down step")
+	ErrUnknownVersion = errors.New("unknown migration version")
+	ErrInvalidVersion = errors.New("invalid migration version")
+)
+
+// Func is a migration step. ctx carries sess, so monarch collection methods
+// called with ctx take part in the step's transaction.
+type Func func(ctx context.Context, m *monarch.Monarch, sess *mongo.Session) error
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      Func
+	Down    Func
+	// NoTransaction runs the migration outside a transaction, e.g. for index
+	// builds or collection drops the server rejects inside one.
+	NoTransaction bool
+}
+
+// Status describes a migration and whether it has been applied. Missing is set
+// for versions found in the ledger that are not registered.
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	Missing   bool
+}
+
+type record struct {
+	Version   int64     `bson:"_id"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"applied_at"`
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []Migration
+)
+
+// Register adds a migration to the package registry used by New. It panics if
+// the version is not positive or is already registered, and is meant to be
+// called from init functions.
+func Register(version int64, name string, up, down Func) {
+	RegisterMigration(Migration{Version: version, Name: name, Up: up, Down: down})
+}
+
+// RegisterMigration is like Register but takes the whole Migration, e.g. to
+// set NoTransaction.
+func RegisterMigration(mig Migration) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if err := validate(registry, mig); err != nil {
+		panic(err)
+	}
+	registry = append(registry, mig)
+}
+
+// Registered returns the registered migrations sorted by version.
+func Registered() []Migration {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	migrations := slices.Clone(registry)
+	slices.SortFunc(migrations, compare)
+	return migrations
+}
+
+type Options func(*Migrator) error
+
+// WithMigrations adds migrations next to the registered ones.
+func WithMigrations(migrations ...Migration) Options {
+	return func(mg *Migrator) error {
+		for _, mig := range migrations {
+			if err := validate(mg.migrations, mig); err != nil {
+				return err
+			}
+			mg.migrations = append(mg.migrations, mig)
+		}
+		return nil
+	}
+}
+
+// Collection sets the ledger collection, monarch_migrations by default.
+func Collection(name string) Options {
+	return func(mg *Migrator) error {
+		if name == "" {
+			return errors.New("error, empty collection name")
+		}
+		mg.collection = name
+		return nil
+	}
+}
+
+// LockTimeout sets how long a lock is honoured before another runner may take
+// it over, for runners that died without releasing it. A running Migrator
+// renews its lock every third of the timeout, which must be at least
+// MinLockTimeout.
+func LockTimeout(d time.Duration) Options {
+	return func(mg *Migrator) error {
+		if d < MinLockTimeout {
+			return fmt.Errorf("error, lock timeout must be at least %v", MinLockTimeout)
+		}
+		mg.lockTimeout = d
+		return nil
+	}
+}
+
+type Migrator struct {
+	m           *monarch.Monarch
+	migrations  []Migration
+	collection  string
+	lockTimeout time.Duration
+	owner       string
+}
+
+// New returns a Migrator for the registered migrations and those passed with
+// WithMigrations.
+func New(m *monarch.Monarch, opts ...Options) (*Migrator, error) {
+	mg := &Migrator{
+		m:           m,
+		migrations:  Registered(),
+		collection:  DefaultCollection,
+		lockTimeout: DefaultLockTimeout,
+		owner:       uuid.NewString(),
+	}
+	for _, opt := range opts {
+		if err := opt(mg); err != nil {
+			return nil, err
+		}
+	}
+	slices.SortFunc(mg.migrations, compare)
+	return mg, nil
+}
+
+// Migrations returns the known migrations sorted by version.
+func (mg *Migrator) Migrations() []Migration {
+	return slices.Clone(mg.migrations)
+}
+
+// Status lists every known migration and every version recorded in the
+// ledger, sorted by version.
+func (mg *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := mg.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var status []Status
+	for _, mig := range mg.migrations {
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if r, ok := applied[mig.Version]; ok {
+			s.Applied, s.AppliedAt = true, r.AppliedAt
+			delete(applied, mig.Version)
+		}
+		status = append(status, s)
+	}
+	for _, r := range applied {
+		status = append(status, Status{Version: r.Version, Name: r.Name, Applied: true, AppliedAt: r.AppliedAt, Missing: true})
+	}
+	slices.SortFunc(status, func(a, b Status) int { return cmp.Compare(a.Version, b.Version) })
+	return status, nil
+}
+
+// Up applies every pending migration in version order and returns the
+// migrations it ran.
+func (mg *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	return mg.to(ctx, math.MaxInt64)
+}
+
+// Down reverts the most recently applied migration, if any.
+func (mg *Migrator) Down(ctx context.Context) ([]Migration, error) {
+	var ran []Migration
+	err := mg.locked(ctx, func(ctx context.Context) error {
+		applied, err := mg.applied(ctx)
+		if err != nil {
+			return err
+		}
+		var latest int64
+		for v := range applied {
+			latest = max(latest, v)
+		}
+		if latest == 0 {
+			return nil
+		}
+		mig, err := mg.lookup(latest)
+		if err != nil {
+			return err
+		}
+		if err := mg.run(ctx, mig, false); err != nil {
+			return err
+		}
+		ran = append(ran, mig)
+		return nil
+	})
+	return ran, err
+}
+
+// To migrates to version: pending migrations up to and including it are
+// applied in ascending order, then applied migrations above it are reverted in
+// descending order. Version 0 reverts everything.
+func (mg *Migrator) To(ctx context.Context, version int64) ([]Migration, error) {
+	if version < 0 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidVersion, version)
+	}
+	if version != 0 {
+		if _, err := mg.lookup(version); err != nil {
+			return nil, err
+		}
+	}
+	return mg.to(ctx, version)
+}
+
+func (mg *Migrator) to(ctx context.Context, version int64) ([]Migration, error) {
+	var ran []Migration
+	err := mg.locked(ctx, func(ctx context.Context) error {
+		applied, err := mg.applied(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range mg.migrations {
+			if _, ok := applied[mig.Version]; ok || mig.Version > version {
+				continue
+			}
+			if err := mg.run(ctx, mig, true); err != nil {
+				return err
+			}
+			ran = append(ran, mig)
+		}
+
+		var revert []int64
+		for v := range applied {
+			if v > version {
+				revert = append(revert, v)
+			}
+		}
+		slices.Sort(revert)
+		slices.Reverse(revert)
+		for _, v := range revert {
+			mig, err := mg.lookup(v)
+			if err != nil {
+				return err
+			}
+			if err := mg.run(ctx, mig, false); err != nil {
+				return err
+			}
+			ran = append(ran, mig)
+		}
+		return nil
+	})
+	return ran, err
+}
+
+func (mg *Migrator) run(ctx context.Context, mig Migration, up bool) error {
+	fn := mig.Up
+	if !up {
+		fn = mig.Down
+	}
+	if fn == nil {
+		return fmt.Errorf("%w: %d %s", ErrIrreversible, mig.Version, mig.Name)
+	}
+
+	db := mg.m.Database()
+	sess, err := db.Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer sess.EndSession(context.WithoutCancel(ctx))
+
+	coll := db.Collection(mg.collection)
+	step := func(ctx context.Context) error {
+		if err := fn(ctx, mg.m, sess); err != nil {
+			return fmt.Errorf("migration %d %s: %w", mig.Version, mig.Name, err)
+		}
+		if !up {
+			_, err := coll.DeleteOne(ctx, bson.D{{Key: "_id", Value: mig.Version}})
+			return err
+		}
+		_, err := coll.InsertOne(ctx, record{Version: mig.Version, Name: mig.Name, AppliedAt: time.Now().UTC()})
+		return err
+	}
+
+	tx, err := mg.transactions(ctx)
+	if err != nil {
+		return err
+	}
+	if !tx || mig.NoTransaction {
+		return step(mongo.NewSessionContext(ctx, sess))
+	}
+	_, err = sess.WithTransaction(ctx, func(ctx context.Context) (any, error) {
+		return nil, step(ctx)
+	})
+	return err
+}
+
+// transactions reports whether the server supports transactions, i.e. whether
+// it is a replica set member or a mongos.
+func (mg *Migrator) transactions(ctx context.Context) (bool, error) {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	admin := mg.m.Database().Client().Database("admin")
+	if err := admin.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		return false, err
+	}
+	return hello.SetName != "" || hello.Msg == "isdbgrid", nil
+}
+
+func (mg *Migrator) applied(ctx context.Context) (map[int64]record, error) {
+	coll := mg.m.Database().Collection(mg.collection)
+	cursor, err := coll.Find(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$ne", Value: lockID}}}})
+	if err != nil {
+		return nil, err
+	}
+	var records []record
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int64]record, len(records))
+	for _, r := range records {
+		applied[r.Version] = r
+	}
+	return applied, nil
+}
+
+// locked runs fn while holding the ledger lock. A lock older than the lock
+// timeout is taken over. The lock is renewed while fn runs; if another runner
+// took it over anyway, the ctx passed to fn is cancelled and ErrLockLost is
+// returned.
+func (mg *Migrator) locked(ctx context.Context, fn func(ctx context.Context) error) error {
+	coll := mg.m.Database().Collection(mg.collection)
+	now := time.Now().UTC()
+	lock := bson.D{{Key: "owner", Value: mg.owner}, {Key: "locked_at", Value: now}}
+
+	_, err := coll.InsertOne(ctx, append(bson.D{{Key: "_id", Value: lockID}}, lock...))
+	if mongo.IsDuplicateKeyError(err) {
+		stale := bson.D{
+			{Key: "_id", Value: lockID},
+			{Key: "locked_at", Value: bson.D{{Key: "$lt", Value: now.Add(-mg.lockTimeout)}}},
+		}
+		err = coll.FindOneAndUpdate(ctx, stale, bson.D{{Key: "$set", Value: lock}}).Err()
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			err = ErrLocked
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	defer coll.DeleteOne(context.WithoutCancel(ctx), bson.D{{Key: "_id", Value: lockID}, {Key: "owner", Value: mg.owner}})
+
+	lockCtx, cancel := context.WithCancelCause(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mg.heartbeat(lockCtx, coll, cancel)
+	}()
+	err = fn(lockCtx)
+	cancel(nil)
+	<-done
+
+	if cause := context.Cause(lockCtx); errors.Is(cause, ErrLockLost) {
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrLockLost, err)
+		}
+		return ErrLockLost
+	}
+	return err
+}
+
+// heartbeat renews locked_at until ctx is done, and cancels it with
+// ErrLockLost once the lock no longer belongs to this runner. Failed renewals
+// are retried on the next tick.
+func (mg *Migrator) heartbeat(ctx context.Context, coll *mongo.Collection, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(mg.lockTimeout / 3)
+	defer ticker.Stop()
+	owned := bson.D{{Key: "_id", Value: lockID}, {Key: "owner", Value: mg.owner}}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		res, err := coll.UpdateOne(ctx, owned, bson.D{{Key: "$set", Value: bson.D{{Key: "locked_at", Value: time.Now().UTC()}}}})
+		if err != nil {
+			continue
+		}
+		if res.MatchedCount == 0 {
+			cancel(ErrLockLost)
+			return
+		}
+	}
+}
+
+func (mg *Migrator) lookup(version int64) (Migration, error) {
+	i, ok := slices.BinarySearchFunc(mg.migrations, version, func(m Migration, v int64) int {
+		return cmp.Compare(m.Version, v)
+	})
+	if !ok {
+		return Migration{}, fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+	return mg.migrations[i], nil
+}
+
+func validate(migrations []Migration, mig Migration) error {
+	if mig.Version <= 0 {
+		return fmt.Errorf("%w: %d", ErrInvalidVersion, mig.Version)
+	}
+	if mig.Up == nil {
+		return fmt.Errorf("%w: %d has no up step", ErrInvalidVersion, mig.Version)
+	}
+	if slices.ContainsFunc(migrations, func(m Migration) bool { return m.Version == mig.Version }) {
+		return fmt.Errorf("%w: %d registered twice", ErrInvalidVersion, mig.Version)
+	}
+	return nil
+}
+
+func compare(a, b Migration) int {
+	return cmp.Compare(a.Version, b.Version)
+}
@@ -0,0 +1,198 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-monarch/monarch"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/event"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/x/mongo/driver/drivertest"
+)
+
+func noop(context.Context, *monarch.Monarch, *mongo.Session) error {
+	return nil
+}
+
+// mockMonarch returns a Monarch whose driver replies with responses, in order,
+// and the names of the commands it was sent.
+func mockMonarch(t *testing.T, responses ...bson.D) (*monarch.Monarch, *[]string) {
+	t.Helper()
+	var cmds []string
+	conn, err := monarch.Connect("mongodb://localhost:27017", func(opts *options.ClientOptions) error {
+		opts.Deployment = drivertest.NewMockDeployment(append([]bson.D{ok()}, responses...)...)
+		opts.SetMonitor(&event.CommandMonitor{
+			Started: func(_ context.Context, e *event.CommandStartedEvent) { cmds = append(cmds, e.CommandName) },
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := monarch.New(conn)
+	m.UseDB("test")
+	cmds = nil
+	return m, &cmds
+}
+
+func ok(fields ...bson.E) bson.D {
+	return append(bson.D{{Key: "ok", Value: 1}}, fields...)
+}
+
+func cursor(docs ...any) bson.D {
+	return ok(bson.E{Key: "cursor", Value: bson.D{
+		{Key: "id", Value: int64(0)},
+		{Key: "ns", Value: "test." + DefaultCollection},
+		{Key: "firstBatch", Value: bson.A(docs)},
+	}})
+}
+
+func TestValidate(t *testing.T) {
+	existing := []Migration{{Version: 1, Up: noop}}
+	for name, mig := range map[string]Migration{
+		"zero version":     {Version: 0, Up: noop},
+		"negative version": {Version: -1, Up: noop},
+		"no up step":       {Version: 2},
+		"duplicate":        {Version: 1, Up: noop},
+	} {
+		if err := validate(existing, mig); !errors.Is(err, ErrInvalidVersion) {
+			t.Errorf("%s: got %v, want ErrInvalidVersion", name, err)
+		}
+	}
+	if err := validate(existing, Migration{Version: 2, Up: noop}); err != nil {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	registryMu.Lock()
+	saved := registry
+	registry = nil
+	registryMu.Unlock()
+	t.Cleanup(func() {
+		registryMu.Lock()
+		registry = saved
+		registryMu.Unlock()
+	})
+
+	Register(3, "third", noop, nil)
+	RegisterMigration(Migration{Version: 1, Name: "first", Up: noop, NoTransaction: true})
+	Register(2, "second", noop, noop)
+
+	var versions []int64
+	for _, mig := range Registered() {
+		versions = append(versions, mig.Version)
+	}
+	if want := []int64{1, 2, 3}; !reflect.DeepEqual(versions, want) {
+		t.Errorf("got %v, want %v", versions, want)
+	}
+	if !Registered()[0].NoTransaction {
+		t.Error("NoTransaction lost")
+	}
+
+	defer func() {
+		if err, _ := recover().(error); !errors.Is(err, ErrInvalidVersion) {
+			t.Errorf("got panic %v, want ErrInvalidVersion", err)
+		}
+	}()
+	Register(2, "again", noop, nil)
+}
+
+func TestNew(t *testing.T) {
+	m, _ := mockMonarch(t)
+	mg, err := New(m, WithMigrations(Migration{Version: 20, Up: noop}, Migration{Version: 10, Up: noop}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versions []int64
+	for _, mig := range mg.Migrations() {
+		versions = append(versions, mig.Version)
+	}
+	if want := []int64{10, 20}; !reflect.DeepEqual(versions, want) {
+		t.Errorf("got %v, want %v", versions, want)
+	}
+
+	if mig, err := mg.lookup(20); err != nil || mig.Version != 20 {
+		t.Errorf("lookup 20: got %v, %v", mig, err)
+	}
+	if _, err := mg.lookup(15); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("lookup 15: got %v, want ErrUnknownVersion", err)
+	}
+	if _, err := mg.To(context.Background(), 15); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("To 15: got %v, want ErrUnknownVersion", err)
+	}
+	if _, err := mg.To(context.Background(), -1); !errors.Is(err, ErrInvalidVersion) {
+		t.Errorf("To -1: got %v, want ErrInvalidVersion", err)
+	}
+
+	for name, opt := range map[string]Options{
+		"duplicate":        WithMigrations(Migration{Version: 1, Up: noop}, Migration{Version: 1, Up: noop}),
+		"empty collection": Collection(""),
+		"zero timeout":     LockTimeout(0),
+		"short timeout":    LockTimeout(2 * time.Nanosecond),
+	} {
+		if _, err := New(m, opt); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestUp(t *testing.T) {
+	m, cmds := mockMonarch(t,
+		ok(bson.E{Key: "n", Value: 1}), // lock
+		cursor(bson.D{{Key: "_id", Value: int64(1)}, {Key: "name", Value: "first"}, {Key: "applied_at", Value: time.Now()}}),
+		ok(),                           // hello, no replica set
+		ok(bson.E{Key: "n", Value: 1}), // record 2
+		ok(bson.E{Key: "n", Value: 1}), // unlock
+	)
+	var calls []int64
+	step := func(v int64) Func {
+		return func(context.Context, *monarch.Monarch, *mongo.Session) error {
+			calls = append(calls, v)
+			return nil
+		}
+	}
+	mg, err := New(m, WithMigrations(
+		Migration{Version: 1, Name: "first", Up: step(1)},
+		Migration{Version: 2, Name: "second", Up: step(2)},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ran, err := mg.Up(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 1 || ran[0].Version != 2 || !reflect.DeepEqual(calls, []int64{2}) {
+		t.Errorf("ran %v, calls %v, want only version 2", ran, calls)
+	}
+	if want := []string{"insert", "find", "hello", "insert", "delete"}; !reflect.DeepEqual(*cmds, want) {
+		t.Errorf("got commands %v, want %v", *cmds, want)
+	}
+}
+
+func TestLocked(t *testing.T) {
+	m, cmds := mockMonarch(t,
+		ok(bson.E{Key: "n", Value: 0}, bson.E{Key: "writeErrors", Value: bson.A{bson.D{
+			{Key: "index", Value: 0},
+			{Key: "code", Value: 11000},
+			{Key: "errmsg", Value: "E11000 duplicate key error"},
+		}}}),
+		ok(bson.E{Key: "value", Value: nil}), // the lock is not stale
+	)
+	mg, err := New(m, WithMigrations(Migration{Version: 1, Up: noop}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mg.Up(context.Background()); !errors.Is(err, ErrLocked) {
+		t.Errorf("got %v, want ErrLocked", err)
+	}
+	if want := []string{"insert", "findAndModify"}; !reflect.DeepEqual(*cmds, want) {
+		t.Errorf("got commands %v, want %v", *cmds, want)
+	}
+}
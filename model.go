@@ -3,6 +3,12 @@ package monarch
 import "time"
 
 type TimeStamp struct {
-	CreatedAt time.Time `monarch:"created_at"`
-	UpdatedAt time.Time `monarch:"updated_at"`
+	CreatedAt time.Time `monarch:"created_at,autoCreateTime"`
+	UpdatedAt time.Time `monarch:"updated_at,autoUpdateTime"`
+}
+
+// SoftDelete marks a model as soft deleted: deletes set DeletedAt instead of
+// removing the document and queries skip documents where it is set.
+type SoftDelete struct {
+	DeletedAt time.Time `monarch:"deleted_at,softDelete"`
 }
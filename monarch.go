@@ -2,6 +2,8 @@ package monarch
 
 import (
 	"context"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -11,6 +13,8 @@ import (
 
 type ConnOptions func(*options.ClientOptions) error
 
+type TxOptions func(*options.TransactionOptionsBuilder) error
+
 type Connection struct {
 	client *mongo.Client
 }
@@ -19,6 +23,9 @@ type Monarch struct {
 	conn       *Connection
 	db         *mongo.Database
 	cacheStore *sync.Map
+	schemas    *sync.Map
+	clock      func() time.Time
+	noIndexes  bool
 }
 
 func Connect(url string, opts ...ConnOptions) (*Connection, error) {
@@ -45,10 +52,81 @@ func Connect(url string, opts ...ConnOptions) (*Connection, error) {
 	return &Connection{client: client}, nil
 }
 
+// Disconnect closes the connection to the server.
+func (c *Connection) Disconnect(ctx context.Context) error {
+	return c.client.Disconnect(ctx)
+}
+
 func New(c *Connection) *Monarch {
-	return &Monarch{conn: c, cacheStore: &sync.Map{}, db: c.client.Database("monarch")}
+	return &Monarch{conn: c, cacheStore: &sync.Map{}, schemas: &sync.Map{}, db: c.client.Database("monarch")}
 }
 
 func (m *Monarch) UseDB(db string) {
 	m.db = m.conn.client.Database(db)
 }
+
+// Database returns the driver database monarch currently works against.
+func (m *Monarch) Database() *mongo.Database {
+	return m.db
+}
+
+// Schemas returns the schemas of the registered collections, sorted by
+// collection name.
+func (m *Monarch) Schemas() []*Schema {
+	var schemas []*Schema
+	m.schemas.Range(func(_, v any) bool {
+		schemas = append(schemas, v.(*Schema))
+		return true
+	})
+	slices.SortFunc(schemas, func(a, b *Schema) int { return strings.Compare(a.Collection, b.Collection) })
+	return schemas
+}
+
+// SetClock replaces the clock used for autoCreateTime and autoUpdateTime
+// fields, e.g. with a fixed time in tests. A nil clock restores time.Now.
+func (m *Monarch) SetClock(clock func() time.Time) {
+	m.clock = clock
+}
+
+// SetAutoIndex controls whether RegisterCollection creates the declared
+// indexes, which it does by default. Disable it to manage indexes with
+// SyncIndexes instead.
+func (m *Monarch) SetAutoIndex(enabled bool) {
+	m.noIndexes = !enabled
+}
+
+func (m *Monarch) now() time.Time {
+	if m.clock == nil {
+		return time.Now()
+	}
+	return m.clock()
+}
+
+// WithTransaction runs fn inside a transaction on a new session. Collection
+// methods called with the ctx passed to fn take part in the transaction. The
+// transaction is committed when fn returns nil and aborted otherwise; transient
+// transaction errors and unknown commit results are retried by the driver.
+// When ctx already carries a session, fn joins it instead of starting another.
+func (m *Monarch) WithTransaction(ctx context.Context, fn func(ctx context.Context) error, opts ...TxOptions) error {
+	if mongo.SessionFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+
+	txOpts := options.Transaction()
+	for _, opt := range opts {
+		if err := opt(txOpts); err != nil {
+			return err
+		}
+	}
+
+	sess, err := m.conn.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer sess.EndSession(context.WithoutCancel(ctx))
+
+	_, err = sess.WithTransaction(ctx, func(ctx context.Context) (any, error) {
+		return nil, fn(ctx)
+	}, txOpts)
+	return err
+}
@@ -1 +1,1260 @@
 package monarch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/event"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/x/mongo/driver/drivertest"
+)
+
+type testProfile struct {
+	Name string   `monarch:"name"`
+	Nick *string  `monarch:"nick"`
+	Tags []string `monarch:"tags"`
+}
+
+func TestDecodeNull(t *testing.T) {
+	c := &Collection[testProfile]{cacheStore: &sync.Map{}}
+	p, err := c.unMarshal(bson.D{{Key: "name", Value: "ada"}, {Key: "nick", Value: nil}, {Key: "tags", Value: nil}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (testProfile{Name: "ada"}); !reflect.DeepEqual(*p, want) {
+		t.Errorf("got %+v, want %+v", *p, want)
+	}
+}
+
+func TestDecodePointer(t *testing.T) {
+	c := &Collection[testProfile]{cacheStore: &sync.Map{}}
+	p, err := c.unMarshal(bson.D{{Key: "nick", Value: "ace"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Nick == nil || *p.Nick != "ace" {
+		t.Errorf("got nick %v, want ace", p.Nick)
+	}
+}
+
+func TestNot(t *testing.T) {
+	q, err := newQuerier(Not(GreaterThan("a", 1)), Not(LessThan("a", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bson.D{
+		{Key: "a", Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$gt", Value: 1}}}}},
+		{Key: "$nor", Value: bson.A{bson.D{{Key: "a", Value: bson.D{{Key: "$lt", Value: 0}}}}}},
+	}
+	if !reflect.DeepEqual(q.filter, want) {
+		t.Errorf("got %v, want %v", q.filter, want)
+	}
+}
+
+type testStamped struct {
+	Name string `monarch:"name"`
+	TimeStamp
+}
+
+func TestReplacementKeepsCreatedAt(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	c := &Collection[testStamped]{cacheStore: &sync.Map{}, now: func() time.Time { return now }}
+	doc, pipeline, err := c.replacement(context.Background(), &testStamped{Name: "ada"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc != nil {
+		t.Fatalf("got plain replacement %v", doc)
+	}
+	want := mongo.Pipeline{{{Key: "$replaceWith", Value: bson.D{{Key: "$mergeObjects", Value: bson.A{
+		bson.D{{Key: "$literal", Value: bson.D{{Key: "name", Value: "ada"}, {Key: "updated_at", Value: now}}}},
+		bson.D{{Key: "created_at", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$created_at", now}}}}},
+	}}}}}}
+	if !reflect.DeepEqual(pipeline, want) {
+		t.Errorf("got %v, want %v", pipeline, want)
+	}
+}
+
+func TestTouchUpdateUpsert(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	c := &Collection[testStamped]{cacheStore: &sync.Map{}, now: func() time.Time { return now }}
+	u, err := newUpdater(Set("name", "ada"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.touchUpdate(u, true); err != nil {
+		t.Fatal(err)
+	}
+	want := bson.D{
+		{Key: "$set", Value: bson.D{{Key: "name", Value: "ada"}, {Key: "updated_at", Value: now}}},
+		{Key: "$setOnInsert", Value: bson.D{{Key: "created_at", Value: now}}},
+	}
+	if !reflect.DeepEqual(u.update, want) {
+		t.Errorf("got %v, want %v", u.update, want)
+	}
+}
+
+type testAddress struct {
+	Zip string `monarch:"zip,required"`
+}
+
+type testContact struct {
+	Home   *testAddress   `monarch:"home"`
+	Work   *testAddress   `monarch:"work"`
+	Others []*testAddress `monarch:"others"`
+}
+
+func TestMarshalStructPointer(t *testing.T) {
+	c := &Collection[testContact]{cacheStore: &sync.Map{}}
+	in := testContact{Home: &testAddress{Zip: "1"}, Others: []*testAddress{{Zip: "2"}}}
+	doc, err := c.marshal(context.Background(), in, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bson.D{
+		{Key: "home", Value: bson.D{{Key: "zip", Value: "1"}}},
+		{Key: "work", Value: nil},
+		{Key: "others", Value: bson.A{bson.D{{Key: "zip", Value: "2"}}}},
+	}
+	if !reflect.DeepEqual(doc, want) {
+		t.Fatalf("got %v, want %v", doc, want)
+	}
+
+	out, err := c.unMarshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*out, in) {
+		t.Errorf("round trip got %+v, want %+v", *out, in)
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	type account struct {
+		Name string       `monarch:"name,required,min=2,max=40"`
+		Role string       `monarch:"role,enum=admin|member"`
+		Age  int          `monarch:"age,min=0"`
+		Home *testAddress `monarch:"home"`
+	}
+	s, err := parse(&account{}, &sync.Map{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bson.D{
+		{Key: "bsonType", Value: "object"},
+		{Key: "required", Value: bson.A{"name"}},
+		{Key: "properties", Value: bson.D{
+			{Key: "name", Value: bson.D{{Key: "bsonType", Value: "string"}, {Key: "minLength", Value: int64(2)}, {Key: "maxLength", Value: int64(40)}}},
+			{Key: "role", Value: bson.D{{Key: "bsonType", Value: "string"}, {Key: "enum", Value: bson.A{"admin", "member"}}}},
+			{Key: "age", Value: bson.D{{Key: "bsonType", Value: bson.A{"int", "long"}}, {Key: "minimum", Value: float64(0)}}},
+			{Key: "home", Value: bson.D{
+				{Key: "bsonType", Value: bson.A{"object", "null"}},
+				{Key: "required", Value: bson.A{"zip"}},
+				{Key: "properties", Value: bson.D{{Key: "zip", Value: bson.D{{Key: "bsonType", Value: "string"}}}}},
+			}},
+		}},
+	}
+	if got := s.JSONSchema(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestJSONSchemaNullable(t *testing.T) {
+	type customer struct {
+		Name string `monarch:"name,required"`
+		Note string `monarch:"note,omitempty"`
+		SoftDelete
+	}
+	s, err := parse(&customer{}, &sync.Map{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bson.D{
+		{Key: "bsonType", Value: "object"},
+		{Key: "required", Value: bson.A{"name"}},
+		{Key: "properties", Value: bson.D{
+			{Key: "name", Value: bson.D{{Key: "bsonType", Value: "string"}}},
+			{Key: "note", Value: bson.D{{Key: "bsonType", Value: bson.A{"string", "null"}}}},
+			{Key: "deleted_at", Value: bson.D{{Key: "bsonType", Value: bson.A{"date", "null"}}}},
+		}},
+	}
+	if got := s.JSONSchema(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestUpsertReturnsAfter(t *testing.T) {
+	q, err := newQuerier(Equals("email", "ada@example.com"), WithUpsert())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := q.returnDocument(); got != options.After {
+		t.Errorf("got %v, want options.After", got)
+	}
+}
+
+func TestEmptyArrayOperators(t *testing.T) {
+	q, err := newQuerier(In("a"), NotIn("b"), All("c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bson.D{
+		{Key: "a", Value: bson.D{{Key: "$in", Value: bson.A{}}}},
+		{Key: "b", Value: bson.D{{Key: "$nin", Value: bson.A{}}}},
+		{Key: "c", Value: bson.D{{Key: "$all", Value: bson.A{}}}},
+	}
+	if !reflect.DeepEqual(q.filter, want) {
+		t.Errorf("got %v, want %v", q.filter, want)
+	}
+
+	u, err := newUpdater(PullAll("tags"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (bson.D{{Key: "$pullAll", Value: bson.D{{Key: "tags", Value: bson.A{}}}}}); !reflect.DeepEqual(u.update, want) {
+		t.Errorf("got %v, want %v", u.update, want)
+	}
+}
+
+func TestQuerierFilter(t *testing.T) {
+	tests := []struct {
+		name  string
+		query []QueryOptions
+		want  bson.D
+	}{
+		{
+			name:  "equals",
+			query: []QueryOptions{Equals("name", "ada")},
+			want:  bson.D{{Key: "name", Value: "ada"}},
+		},
+		{
+			name:  "merge per key",
+			query: []QueryOptions{Equals("age", 30), GreaterThan("age", 18), LessThan("age", 65)},
+			want:  bson.D{{Key: "age", Value: bson.D{{Key: "$eq", Value: 30}, {Key: "$gt", Value: 18}, {Key: "$lt", Value: 65}}}},
+		},
+		{
+			name:  "in",
+			query: []QueryOptions{In("id", 1, 2)},
+			want:  bson.D{{Key: "id", Value: bson.D{{Key: "$in", Value: bson.A{1, 2}}}}},
+		},
+		{
+			name:  "repeated equals",
+			query: []QueryOptions{Equals("tenant_id", "t1"), Equals("tenant_id", "t2")},
+			want: bson.D{
+				{Key: "tenant_id", Value: "t1"},
+				{Key: "$and", Value: bson.A{bson.D{{Key: "tenant_id", Value: bson.D{{Key: "$eq", Value: "t2"}}}}}},
+			},
+		},
+		{
+			name:  "repeated gt",
+			query: []QueryOptions{GreaterThan("age", 65), LessThan("age", 90), GreaterThan("age", 18)},
+			want: bson.D{
+				{Key: "age", Value: bson.D{{Key: "$gt", Value: 65}, {Key: "$lt", Value: 90}}},
+				{Key: "$and", Value: bson.A{bson.D{{Key: "age", Value: bson.D{{Key: "$gt", Value: 18}}}}}},
+			},
+		},
+		{
+			name:  "repeated in",
+			query: []QueryOptions{In("id", 1, 2), In("id", 2, 3), In("id", 3)},
+			want: bson.D{
+				{Key: "id", Value: bson.D{{Key: "$in", Value: bson.A{1, 2}}}},
+				{Key: "$and", Value: bson.A{
+					bson.D{{Key: "id", Value: bson.D{{Key: "$in", Value: bson.A{2, 3}}}}},
+					bson.D{{Key: "id", Value: bson.D{{Key: "$in", Value: bson.A{3}}}}},
+				}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := newQuerier(tt.query...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(q.filter, tt.want) {
+				t.Errorf("got %v, want %v", q.filter, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuerierErrors(t *testing.T) {
+	for name, query := range map[string]QueryOptions{
+		"bad order": OrderBy("a", OrderType(5)),
+	} {
+		if _, err := newQuerier(query); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		name  string
+		query []QueryOptions
+		want  bson.D
+	}{
+		{
+			name:  "or",
+			query: []QueryOptions{Or(Equals("a", 1), Equals("b", 2))},
+			want:  bson.D{{Key: "$or", Value: bson.A{bson.D{{Key: "a", Value: 1}}, bson.D{{Key: "b", Value: 2}}}}},
+		},
+		{
+			name:  "nested in and",
+			query: []QueryOptions{Equals("status", "active"), Or(Equals("role", "admin"), Equals("role", "owner"))},
+			want: bson.D{
+				{Key: "status", Value: "active"},
+				{Key: "$or", Value: bson.A{bson.D{{Key: "role", Value: "admin"}}, bson.D{{Key: "role", Value: "owner"}}}},
+			},
+		},
+		{
+			name:  "repeated or nests under and",
+			query: []QueryOptions{Or(Equals("a", 1), Equals("b", 2)), Or(Equals("c", 3), Equals("d", 4))},
+			want: bson.D{
+				{Key: "$or", Value: bson.A{bson.D{{Key: "a", Value: 1}}, bson.D{{Key: "b", Value: 2}}}},
+				{Key: "$and", Value: bson.A{bson.D{{Key: "$or", Value: bson.A{bson.D{{Key: "c", Value: 3}}, bson.D{{Key: "d", Value: 4}}}}}}},
+			},
+		},
+		{
+			name:  "repeated and extends the clause",
+			query: []QueryOptions{And(Equals("a", 1)), And(Equals("b", 2))},
+			want:  bson.D{{Key: "$and", Value: bson.A{bson.D{{Key: "a", Value: 1}}, bson.D{{Key: "b", Value: 2}}}}},
+		},
+		{
+			name:  "nor",
+			query: []QueryOptions{Nor(Equals("a", 1), LessThan("b", 2))},
+			want:  bson.D{{Key: "$nor", Value: bson.A{bson.D{{Key: "a", Value: 1}}, bson.D{{Key: "b", Value: bson.D{{Key: "$lt", Value: 2}}}}}}},
+		},
+		{
+			name:  "not on a field",
+			query: []QueryOptions{Not(GreaterThan("a", 1))},
+			want:  bson.D{{Key: "a", Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$gt", Value: 1}}}}}},
+		},
+		{
+			name:  "not on an equality",
+			query: []QueryOptions{Not(Equals("a", 1))},
+			want:  bson.D{{Key: "$nor", Value: bson.A{bson.D{{Key: "a", Value: 1}}}}},
+		},
+		{
+			name:  "not on several fields",
+			query: []QueryOptions{Not(And(Equals("a", 1), Equals("b", 2)))},
+			want:  bson.D{{Key: "$nor", Value: bson.A{bson.D{{Key: "$and", Value: bson.A{bson.D{{Key: "a", Value: 1}}, bson.D{{Key: "b", Value: 2}}}}}}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := newQuerier(tt.query...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(q.filter, tt.want) {
+				t.Errorf("got %v, want %v", q.filter, tt.want)
+			}
+		})
+	}
+
+	for name, query := range map[string]QueryOptions{
+		"empty or":  Or(),
+		"nil and":   And(Equals("a", 1), nil),
+		"empty nor": Nor(),
+		"nil not":   Not(nil),
+	} {
+		if _, err := newQuerier(query); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestArrayOperators(t *testing.T) {
+	tests := []struct {
+		name  string
+		query []QueryOptions
+		want  bson.D
+	}{
+		{
+			name:  "all",
+			query: []QueryOptions{All("tags", "a", "b")},
+			want:  bson.D{{Key: "tags", Value: bson.D{{Key: "$all", Value: bson.A{"a", "b"}}}}},
+		},
+		{
+			name:  "contains accumulates",
+			query: []QueryOptions{Contains("tags", "a"), Contains("tags", "b")},
+			want:  bson.D{{Key: "tags", Value: bson.D{{Key: "$all", Value: bson.A{"a", "b"}}}}},
+		},
+		{
+			name:  "elemMatch on documents",
+			query: []QueryOptions{ElemMatch("addresses", Equals("city", "Lagos"), Equals("zip", "100001"))},
+			want:  bson.D{{Key: "addresses", Value: bson.D{{Key: "$elemMatch", Value: bson.D{{Key: "city", Value: "Lagos"}, {Key: "zip", Value: "100001"}}}}}},
+		},
+		{
+			name:  "elemMatch on scalars",
+			query: []QueryOptions{ElemMatch("scores", GreaterThan("", 80), LessThan("", 90))},
+			want:  bson.D{{Key: "scores", Value: bson.D{{Key: "$elemMatch", Value: bson.D{{Key: "$gt", Value: 80}, {Key: "$lt", Value: 90}}}}}},
+		},
+		{
+			name:  "elemMatch on a scalar value",
+			query: []QueryOptions{ElemMatch("scores", Equals("", 80))},
+			want:  bson.D{{Key: "scores", Value: bson.D{{Key: "$elemMatch", Value: bson.D{{Key: "$eq", Value: 80}}}}}},
+		},
+		{
+			name:  "size",
+			query: []QueryOptions{Size("tags", 2)},
+			want:  bson.D{{Key: "tags", Value: bson.D{{Key: "$size", Value: 2}}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := newQuerier(tt.query...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(q.filter, tt.want) {
+				t.Errorf("got %v, want %v", q.filter, tt.want)
+			}
+		})
+	}
+
+	for name, query := range map[string]QueryOptions{
+		"empty elemMatch": ElemMatch("a"),
+		"negative size":   Size("a", -1),
+	} {
+		if _, err := newQuerier(query); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+type testSession struct {
+	ID       string    `monarch:"id,index"`
+	Email    string    `monarch:"email" index:"name=email_tenant,order=1,unique"`
+	TenantID string    `monarch:"tenant_id" index:"name=email_tenant,order=2,desc"`
+	Expires  time.Time `monarch:"expires" index:"ttl=24h"`
+	Bio      string    `monarch:"bio" index:"type=text;name=bio_partial,partial"`
+}
+
+func (testSession) Indexes() []IndexSpec {
+	return []IndexSpec{{Keys: []IndexKey{{Field: "tenant_id", Value: int32(1)}, {Field: "expires", Value: int32(-1)}}}}
+}
+
+func TestBuildIndexes(t *testing.T) {
+	s, err := parse(&testSession{}, &sync.Map{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []IndexSpec{
+		{Name: "id_1", Keys: []IndexKey{{Field: "id", Value: int32(1)}}, Unique: true},
+		{Name: "email_tenant", Keys: []IndexKey{{Field: "email", Value: int32(1)}, {Field: "tenant_id", Value: int32(-1)}}, Unique: true},
+		{Name: "expires_1", Keys: []IndexKey{{Field: "expires", Value: int32(1)}}, TTL: 24 * time.Hour},
+		{Name: "bio_text", Keys: []IndexKey{{Field: "bio", Value: "text"}}},
+		{Name: "bio_partial", Keys: []IndexKey{{Field: "bio", Value: int32(1)}},
+			Partial: bson.D{{Key: "bio", Value: bson.D{{Key: "$exists", Value: true}}}}},
+		{Name: "tenant_id_1_expires_-1", Keys: []IndexKey{{Field: "tenant_id", Value: int32(1)}, {Field: "expires", Value: int32(-1)}}},
+	}
+	if !reflect.DeepEqual(s.Indexes, want) {
+		t.Errorf("got %+v\nwant %+v", s.Indexes, want)
+	}
+}
+
+func TestParseIndexTagErrors(t *testing.T) {
+	for _, tag := range []string{"order=x", "type=btree", "ttl=soon", "primary"} {
+		if _, err := parseIndexTag(tag); err == nil {
+			t.Errorf("%q: expected an error", tag)
+		}
+	}
+
+	type compoundTTL struct {
+		A time.Time `monarch:"a" index:"name=ab,ttl=1h"`
+		B string    `monarch:"b" index:"name=ab"`
+	}
+	if _, err := parse(&compoundTTL{}, &sync.Map{}); !errors.Is(err, ErrInvalidSchema) {
+		t.Errorf("ttl on compound index: got %v, want ErrInvalidSchema", err)
+	}
+
+	type sparsePartial struct {
+		A string `monarch:"a" index:"name=a,sparse"`
+		B string `monarch:"b" index:"name=a,partial"`
+	}
+	if _, err := parse(&sparsePartial{}, &sync.Map{}); !errors.Is(err, ErrInvalidSchema) {
+		t.Errorf("sparse and partial index: got %v, want ErrInvalidSchema", err)
+	}
+}
+
+func TestKeysetFilter(t *testing.T) {
+	order := bson.D{{Key: "score", Value: int32(-1)}, {Key: "_id", Value: int32(1)}}
+	got := keysetFilter(order, bson.A{int32(10), "b"})
+	want := bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "score", Value: bson.D{{Key: "$lt", Value: int32(10)}}}},
+		bson.D{{Key: "score", Value: int32(10)}, {Key: "_id", Value: bson.D{{Key: "$gt", Value: "b"}}}},
+	}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestPageCursor(t *testing.T) {
+	keys := []string{"profile.score", "_id"}
+	doc := bson.D{{Key: "_id", Value: "b"}, {Key: "profile", Value: bson.D{{Key: "score", Value: int32(10)}}}}
+	cursor, err := encodeCursor(keys, doc, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cur, err := decodeCursor(cursor, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (&pageCursor{Keys: keys, Values: bson.A{int32(10), "b"}, Backward: true}); !reflect.DeepEqual(cur, want) {
+		t.Errorf("got %+v, want %+v", cur, want)
+	}
+
+	if _, err := decodeCursor(cursor, []string{"_id"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("other order: got %v, want ErrInvalidCursor", err)
+	}
+	if _, err := decodeCursor("not a cursor!", keys); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("garbage: got %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestExecRawWrapsErrors(t *testing.T) {
+	c := &Collection[testProfile]{cacheStore: &sync.Map{}}
+	err := c.ExecRaw(context.Background(), func(context.Context, *mongo.Collection) error {
+		return mongo.ErrNoDocuments
+	})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+}
+
+type testAuthor struct {
+	ID string `monarch:"id"`
+}
+
+type testPost struct {
+	Title    string      `monarch:"title"`
+	AuthorID string      `monarch:"author_id,ref=authors"`
+	Author   *testAuthor `monarch:"-,rel=author_id"`
+}
+
+func TestParseRelationErrors(t *testing.T) {
+	type sliceKey struct {
+		AuthorIDs []string    `monarch:"author_ids,ref=authors"`
+		Author    *testAuthor `monarch:"-,rel=author_ids"`
+	}
+	if _, err := parse(&sliceKey{}, &sync.Map{}); !errors.Is(err, ErrInvalidSchema) {
+		t.Errorf("single relation on slice key: got %v, want ErrInvalidSchema", err)
+	}
+
+	type mapKey struct {
+		AuthorID map[string]string `monarch:"author_id,ref=authors"`
+		Authors  []testAuthor      `monarch:"-,rel=author_id"`
+	}
+	if _, err := parse(&mapKey{}, &sync.Map{}); !errors.Is(err, ErrInvalidSchema) {
+		t.Errorf("map key: got %v, want ErrInvalidSchema", err)
+	}
+
+	type sliceRelation struct {
+		AuthorIDs []string      `monarch:"author_ids,ref=authors"`
+		Authors   []*testAuthor `monarch:"-,rel=author_ids"`
+	}
+	s, err := parse(&sliceRelation{}, &sync.Map{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rel := s.Relations["Authors"]; rel == nil || !rel.Many {
+		t.Errorf("got relation %+v, want a slice relation", rel)
+	}
+}
+
+func TestCursorPreloadsPerBatch(t *testing.T) {
+	ctx := context.Background()
+	docs := []any{bson.D{{Key: "title", Value: "a"}}, bson.D{{Key: "title", Value: "b"}}, bson.D{{Key: "title", Value: "c"}}}
+	mc, err := mongo.NewCursorFromDocuments(docs, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cur := &Cursor[testPost]{c: &Collection[testPost]{cacheStore: &sync.Map{}}, cur: mc, preload: []string{"Author"}}
+
+	var titles []string
+	for cur.Next(ctx) {
+		if len(cur.batch) != len(docs) {
+			t.Fatalf("got batch of %d documents, want %d", len(cur.batch), len(docs))
+		}
+		p, err := cur.Decode(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		titles = append(titles, p.Title)
+	}
+	if err := cur.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(titles, want) {
+		t.Errorf("got %v, want %v", titles, want)
+	}
+}
+
+// mockCollection returns a collection whose driver replies with responses, in
+// order, and the commands it was sent, for tests that need no server.
+func mockCollection[T any](t *testing.T, responses ...bson.D) (*Collection[T], *[]bson.Raw) {
+	t.Helper()
+	var cmds []bson.Raw
+	opts := options.Client().SetRegistry(mongoRegistry).SetMonitor(&event.CommandMonitor{
+		Started: func(_ context.Context, e *event.CommandStartedEvent) { cmds = append(cmds, e.Command) },
+	})
+	opts.Deployment = drivertest.NewMockDeployment(responses...)
+	client, err := mongo.Connect(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coll := client.Database("test").Collection("items")
+	return &Collection[T]{coll: coll, cacheStore: &sync.Map{}, now: time.Now}, &cmds
+}
+
+func cursorReply(docs ...any) bson.D {
+	return bson.D{{Key: "ok", Value: 1}, {Key: "cursor", Value: bson.D{
+		{Key: "id", Value: int64(0)},
+		{Key: "ns", Value: "test.items"},
+		{Key: "firstBatch", Value: bson.A(docs)},
+	}}}
+}
+
+// commandField decodes the value at path in cmd, e.g. "deletes.0.q".
+func commandField(t *testing.T, cmd bson.Raw, path ...string) any {
+	t.Helper()
+	rv, err := cmd.LookupErr(path...)
+	if err != nil {
+		t.Fatalf("%v not in %v", path, cmd)
+	}
+	var v any
+	if err := rv.Unmarshal(&v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+var errLocked = errors.New("locked")
+
+type testHooked struct {
+	Name string `monarch:"name"`
+}
+
+var hookCalls []string
+
+func (h *testHooked) BeforeDelete(context.Context) error {
+	if h.Name == "locked" {
+		return errLocked
+	}
+	hookCalls = append(hookCalls, "before "+h.Name)
+	return nil
+}
+
+func (h *testHooked) AfterDelete(context.Context) error {
+	hookCalls = append(hookCalls, "after "+h.Name)
+	return nil
+}
+
+func TestDeleteManyHooks(t *testing.T) {
+	hookCalls = nil
+	c, cmds := mockCollection[testHooked](t,
+		cursorReply(bson.D{{Key: "_id", Value: 1}, {Key: "name", Value: "a"}}, bson.D{{Key: "_id", Value: 2}, {Key: "name", Value: "b"}}),
+		bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 2}},
+	)
+	res, err := c.DeleteMany(context.Background(), NotEquals("name", "c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.DeletedCount != 2 {
+		t.Errorf("got %d deleted, want 2", res.DeletedCount)
+	}
+	if want := []string{"before a", "before b", "after a", "after b"}; !reflect.DeepEqual(hookCalls, want) {
+		t.Errorf("got hooks %v, want %v", hookCalls, want)
+	}
+	want := bson.D{{Key: "$and", Value: bson.A{
+		bson.D{{Key: "name", Value: bson.D{{Key: "$ne", Value: "c"}}}},
+		bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: bson.A{int32(1), int32(2)}}}}},
+	}}}
+	if got := commandField(t, (*cmds)[1], "deletes", "0", "q"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got delete filter %v, want %v", got, want)
+	}
+}
+
+func TestDeleteHookAborts(t *testing.T) {
+	hookCalls = nil
+	c, cmds := mockCollection[testHooked](t,
+		cursorReply(bson.D{{Key: "_id", Value: 1}, {Key: "name", Value: "a"}}, bson.D{{Key: "_id", Value: 2}, {Key: "name", Value: "locked"}}),
+	)
+	if _, err := c.ForceDelete(context.Background()); !errors.Is(err, errLocked) {
+		t.Fatalf("got %v, want the hook error", err)
+	}
+	if len(*cmds) != 1 {
+		t.Errorf("got %d commands, want only the find", len(*cmds))
+	}
+}
+
+func TestFindOneAndDeleteHooks(t *testing.T) {
+	hookCalls = nil
+	doc := bson.D{{Key: "_id", Value: 7}, {Key: "name", Value: "a"}}
+	c, cmds := mockCollection[testHooked](t,
+		cursorReply(doc),
+		bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: doc}},
+	)
+	res, err := c.FindOneAndDelete(context.Background(), Equals("name", "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Name != "a" {
+		t.Errorf("got %+v", res)
+	}
+	if want := []string{"before a", "after a"}; !reflect.DeepEqual(hookCalls, want) {
+		t.Errorf("got hooks %v, want %v", hookCalls, want)
+	}
+	want := bson.D{{Key: "$and", Value: bson.A{bson.D{{Key: "name", Value: "a"}}, bson.D{{Key: "_id", Value: int32(7)}}}}}
+	if got := commandField(t, (*cmds)[1], "query"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got delete filter %v, want %v", got, want)
+	}
+}
+
+func TestBulkDeleteHooks(t *testing.T) {
+	hookCalls = nil
+	c, _ := mockCollection[testHooked](t, cursorReply(bson.D{{Key: "_id", Value: 3}, {Key: "name", Value: "a"}}))
+	b, err := c.BulkWriter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Delete(context.Background(), Equals("name", "a")); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"before a"}; !reflect.DeepEqual(hookCalls, want) {
+		t.Errorf("got hooks %v, want %v", hookCalls, want)
+	}
+	want := bson.D{{Key: "$and", Value: bson.A{bson.D{{Key: "name", Value: "a"}}, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: bson.A{int32(3)}}}}}}}}
+	if got := b.models[0].(*mongo.DeleteOneModel).Filter; !reflect.DeepEqual(got, want) {
+		t.Errorf("got filter %v, want %v", got, want)
+	}
+}
+
+func TestBulkWriteErrors(t *testing.T) {
+	s, err := parse(&testProfile{}, &sync.Map{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bwe := mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{
+		{WriteError: mongo.WriteError{Index: 0, Code: 121, Message: "Document failed validation"}},
+		{WriteError: mongo.WriteError{Index: 2, Code: 11000, Message: `E11000 duplicate key error collection: test.items index: name_1 dup key: { name: "ada" }`}},
+	}}
+	errs, ok := bulkWriteErrors(bwe, 1000, s)
+	if !ok {
+		t.Fatal("not mapped")
+	}
+	if len(errs) != 2 || errs[0].Index != 1000 || errs[1].Index != 1002 {
+		t.Fatalf("got %+v, want indexes 1000 and 1002", errs)
+	}
+	var dup *DuplicateKeyError
+	if !errors.As(errs[1].Err, &dup) || dup.Index != "name_1" || dup.Field == nil || dup.Field.Name != "Name" {
+		t.Errorf("got %v, want a duplicate key on Name", errs[1].Err)
+	}
+	if errors.Is(errs[0].Err, ErrDuplicateKey) {
+		t.Errorf("validation error mapped to a duplicate key")
+	}
+
+	if _, ok := bulkWriteErrors(errors.New("network"), 0, s); ok {
+		t.Error("plain error mapped as a bulk write error")
+	}
+}
+
+func TestSaveManyPartialResult(t *testing.T) {
+	c, _ := mockCollection[testProfile](t, bson.D{
+		{Key: "ok", Value: 1},
+		{Key: "n", Value: 1},
+		{Key: "writeErrors", Value: bson.A{bson.D{
+			{Key: "index", Value: 1},
+			{Key: "code", Value: 11000},
+			{Key: "errmsg", Value: `E11000 duplicate key error collection: test.items index: name_1 dup key: { name: "b" }`},
+		}}},
+	})
+	res, err := c.SaveMany(context.Background(), []testProfile{{Name: "a"}, {Name: "b"}, {Name: "c"}})
+	var bulkErr *BulkError
+	if !errors.As(err, &bulkErr) || len(bulkErr.WriteErrors) != 1 || bulkErr.WriteErrors[0].Index != 1 {
+		t.Fatalf("got %v, want a bulk error on item 1", err)
+	}
+	if res == nil || res.InsertedCount != 1 || len(res.InsertedIDs) != 1 {
+		t.Errorf("got %+v, want the id of the first insert", res)
+	}
+}
+
+func TestBulkUpdateUpsert(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	c := &Collection[testStamped]{cacheStore: &sync.Map{}, now: func() time.Time { return now }}
+	b, err := c.BulkWriter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Update([]UpdateOption{Set("name", "ada")}, Equals("name", "ada"), WithUpsert()); err != nil {
+		t.Fatal(err)
+	}
+	m := b.models[0].(*mongo.UpdateOneModel)
+	if m.Upsert == nil || !*m.Upsert {
+		t.Error("upsert not set")
+	}
+	want := bson.D{
+		{Key: "$set", Value: bson.D{{Key: "name", Value: "ada"}, {Key: "updated_at", Value: now}}},
+		{Key: "$setOnInsert", Value: bson.D{{Key: "created_at", Value: now}}},
+	}
+	if !reflect.DeepEqual(m.Update, want) {
+		t.Errorf("got %v, want %v", m.Update, want)
+	}
+}
+
+func TestWrapError(t *testing.T) {
+	s, err := parse(&testProfile{}, &sync.Map{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPattern, err := bson.Marshal(bson.D{{Key: "keyPattern", Value: bson.D{{Key: "nick", Value: 1}, {Key: "name", Value: 1}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		err   error
+		index string
+		keys  []string
+		field string
+	}{
+		{
+			name:  "message",
+			err:   mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000, Message: `E11000 duplicate key error collection: test.items index: name_1 dup key: { name: "ada" }`}}},
+			index: "name_1",
+			keys:  []string{"name"},
+			field: "Name",
+		},
+		{
+			name:  "key pattern",
+			err:   mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000, Message: `E11000 duplicate key error collection: test.items index: nick_name dup key: { : "a", : "b" }`, Raw: keyPattern}}},
+			index: "nick_name",
+			keys:  []string{"nick", "name"},
+			field: "Nick",
+		},
+		{
+			name:  "legacy message",
+			err:   mongo.CommandError{Code: 11001, Message: `E11000 duplicate key error index: test.items.$tags_1 dup key: { : "x" }`},
+			index: "test.items.$tags_1",
+		},
+		{
+			name:  "unknown field",
+			err:   mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000, Message: `E11000 duplicate key error collection: test.items index: _id_ dup key: { _id: "x" }`}}},
+			index: "_id_",
+			keys:  []string{"_id"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := wrapError(tt.err, s)
+			var dup *DuplicateKeyError
+			if !errors.As(err, &dup) || !errors.Is(err, ErrDuplicateKey) {
+				t.Fatalf("got %v, want a duplicate key error", err)
+			}
+			if dup.Index != tt.index || !reflect.DeepEqual(dup.Keys, tt.keys) {
+				t.Errorf("got index %q keys %v, want %q %v", dup.Index, dup.Keys, tt.index, tt.keys)
+			}
+			if field := ""; dup.Field != nil {
+				field = dup.Field.Name
+				if field != tt.field {
+					t.Errorf("got field %s, want %s", field, tt.field)
+				}
+			} else if tt.field != "" {
+				t.Errorf("got no field, want %s", tt.field)
+			}
+			if !reflect.DeepEqual(dup.Unwrap(), tt.err) {
+				t.Error("driver error not in the chain")
+			}
+		})
+	}
+
+	if err := wrapError(mongo.ErrNoDocuments, s); !errors.Is(err, ErrNotFound) || !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Errorf("got %v, want ErrNotFound wrapping mongo.ErrNoDocuments", err)
+	}
+}
+
+func TestCountWrapsErrors(t *testing.T) {
+	c, _ := mockCollection[testProfile](t, bson.D{
+		{Key: "ok", Value: 0},
+		{Key: "code", Value: 11000},
+		{Key: "errmsg", Value: `E11000 duplicate key error collection: test.items index: name_1 dup key: { name: "ada" }`},
+	})
+	if _, err := c.Count(context.Background()); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("got %v, want a wrapped error", err)
+	}
+}
+
+type testArchived struct {
+	Name string `monarch:"name"`
+	SoftDelete
+}
+
+func TestSoftDeleteScope(t *testing.T) {
+	c := &Collection[testArchived]{cacheStore: &sync.Map{}}
+	tests := []struct {
+		name  string
+		query []QueryOptions
+		want  bson.D
+	}{
+		{
+			name:  "default",
+			query: []QueryOptions{Equals("name", "ada")},
+			want:  bson.D{{Key: "name", Value: "ada"}, {Key: "deleted_at", Value: nil}},
+		},
+		{
+			name:  "with deleted",
+			query: []QueryOptions{Equals("name", "ada"), WithDeleted()},
+			want:  bson.D{{Key: "name", Value: "ada"}},
+		},
+		{
+			name:  "only deleted",
+			query: []QueryOptions{OnlyDeleted(), Equals("name", "ada")},
+			want:  bson.D{{Key: "name", Value: "ada"}, {Key: "deleted_at", Value: bson.D{{Key: "$ne", Value: nil}}}},
+		},
+		{
+			name:  "caller condition on deleted_at",
+			query: []QueryOptions{GreaterThan("deleted_at", 1)},
+			want:  bson.D{{Key: "deleted_at", Value: bson.D{{Key: "$gt", Value: 1}, {Key: "$eq", Value: nil}}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := c.query(tt.query...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(q.filter, tt.want) {
+				t.Errorf("got %v, want %v", q.filter, tt.want)
+			}
+		})
+	}
+
+	plain := &Collection[testProfile]{cacheStore: &sync.Map{}}
+	q, err := plain.query(Equals("name", "ada"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (bson.D{{Key: "name", Value: "ada"}}); !reflect.DeepEqual(q.filter, want) {
+		t.Errorf("without SoftDelete got %v, want %v", q.filter, want)
+	}
+}
+
+func TestSoftDeleteOne(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	c, cmds := mockCollection[testArchived](t, bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}})
+	c.now = func() time.Time { return now }
+	res, err := c.DeleteOne(context.Background(), Equals("name", "ada"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (&Result{MatchedCount: 1, ModifiedCount: 1, DeletedCount: 1}); !reflect.DeepEqual(res, want) {
+		t.Errorf("got %+v, want %+v", res, want)
+	}
+	cmd := (*cmds)[0]
+	if name := cmd.Index(0).Key(); name != "update" {
+		t.Fatalf("got %s command, want update", name)
+	}
+	if got, want := commandField(t, cmd, "updates", "0", "q"), (bson.D{{Key: "name", Value: "ada"}, {Key: "deleted_at", Value: nil}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got filter %v, want %v", got, want)
+	}
+	if got, want := commandField(t, cmd, "updates", "0", "u"), (bson.D{{Key: "$set", Value: bson.D{{Key: "deleted_at", Value: bson.NewDateTimeFromTime(now)}}}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got update %v, want %v", got, want)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	c, cmds := mockCollection[testArchived](t, bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 2}, {Key: "nModified", Value: 2}})
+	res, err := c.Restore(context.Background(), Equals("name", "ada"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.MatchedCount != 2 || res.ModifiedCount != 2 {
+		t.Errorf("got %+v, want 2 restored", res)
+	}
+	cmd := (*cmds)[0]
+	if got, want := commandField(t, cmd, "updates", "0", "q"), (bson.D{{Key: "name", Value: "ada"}, {Key: "deleted_at", Value: bson.D{{Key: "$ne", Value: nil}}}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got filter %v, want %v", got, want)
+	}
+	if got, want := commandField(t, cmd, "updates", "0", "u"), (bson.D{{Key: "$unset", Value: bson.D{{Key: "deleted_at", Value: ""}}}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got update %v, want %v", got, want)
+	}
+	if got := commandField(t, cmd, "updates", "0", "multi"); got != true {
+		t.Errorf("got multi %v, want true", got)
+	}
+}
+
+func TestPipelineStages(t *testing.T) {
+	p := NewPipeline().
+		Match(Equals("status", "paid"), GreaterThan("amount", 10)).
+		Unwind("items", true).
+		Group("$customer", bson.D{{Key: "total", Value: bson.D{{Key: "$sum", Value: "$amount"}}}}).
+		Sort("total", DESC).
+		Sort("_id", ASC).
+		Facet("top", NewPipeline().Limit(3)).
+		Facet("count", NewPipeline().Count("n")).
+		Skip(1)
+	got, err := p.Stages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "status", Value: "paid"}, {Key: "amount", Value: bson.D{{Key: "$gt", Value: 10}}}}}},
+		{{Key: "$unwind", Value: bson.D{{Key: "path", Value: "$items"}, {Key: "preserveNullAndEmptyArrays", Value: true}}}},
+		{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$customer"}, {Key: "total", Value: bson.D{{Key: "$sum", Value: "$amount"}}}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "total", Value: int32(-1)}, {Key: "_id", Value: int32(1)}}}},
+		{{Key: "$facet", Value: bson.D{
+			{Key: "top", Value: mongo.Pipeline{{{Key: "$limit", Value: int64(3)}}}},
+			{Key: "count", Value: mongo.Pipeline{{{Key: "$count", Value: "n"}}}},
+		}}},
+		{{Key: "$skip", Value: int64(1)}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+
+	// a stage in between starts a new $sort
+	got, _ = NewPipeline().Sort("a", ASC).Limit(1).Sort("b", ASC).Stages()
+	if len(got) != 3 {
+		t.Errorf("got %v, want three stages", got)
+	}
+}
+
+func TestPipelineErrors(t *testing.T) {
+	for name, p := range map[string]*Pipeline{
+		"bad order":     NewPipeline().Sort("a", OrderType(5)),
+		"bad match":     NewPipeline().Match(Or()),
+		"nil facet":     NewPipeline().Facet("a", nil),
+		"failing facet": NewPipeline().Facet("a", NewPipeline().Sort("a", OrderType(5))),
+	} {
+		stages, err := p.Limit(1).Stages()
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+		if len(stages) != 0 {
+			t.Errorf("%s: got stages %v after the error", name, stages)
+		}
+	}
+}
+
+func TestAggregateSoftDeleteMatch(t *testing.T) {
+	type total struct {
+		Name  string `monarch:"_id"`
+		Count int    `monarch:"count"`
+	}
+	c, cmds := mockCollection[testArchived](t, cursorReply(bson.D{{Key: "_id", Value: "ada"}, {Key: "count", Value: 2}}))
+	res, err := Aggregate[total](context.Background(), c, NewPipeline().Group("$name", bson.D{{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []total{{Name: "ada", Count: 2}}; !reflect.DeepEqual(res, want) {
+		t.Errorf("got %+v, want %+v", res, want)
+	}
+	want := bson.A{
+		bson.D{{Key: "$match", Value: bson.D{{Key: "deleted_at", Value: nil}}}},
+		bson.D{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$name"}, {Key: "count", Value: bson.D{{Key: "$sum", Value: int32(1)}}}}}},
+	}
+	if got := commandField(t, (*cmds)[0], "pipeline"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got pipeline %v, want %v", got, want)
+	}
+}
+
+func TestPrefixFilter(t *testing.T) {
+	q, err := newQuerier(
+		Equals("email", "a@b.c"),
+		GreaterThan("age", 18),
+		Or(Equals("role", "admin"), And(Equals("role", "owner"), Equals("active", true))),
+		Not(Equals("banned", true)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bson.D{
+		{Key: "fullDocument.email", Value: "a@b.c"},
+		{Key: "fullDocument.age", Value: bson.D{{Key: "$gt", Value: 18}}},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "fullDocument.role", Value: "admin"}},
+			bson.D{{Key: "$and", Value: bson.A{bson.D{{Key: "fullDocument.role", Value: "owner"}}, bson.D{{Key: "fullDocument.active", Value: true}}}}},
+		}},
+		{Key: "$nor", Value: bson.A{bson.D{{Key: "fullDocument.banned", Value: true}}}},
+	}
+	if got := prefixFilter("fullDocument.", q.filter); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+}
+
+type testTokens struct {
+	saved []bson.Raw
+}
+
+func (s *testTokens) LoadToken(context.Context) (bson.Raw, error) {
+	return nil, nil
+}
+
+func (s *testTokens) SaveToken(_ context.Context, token bson.Raw) error {
+	s.saved = append(s.saved, token)
+	return nil
+}
+
+func TestChangeStreamSavesTokenOnBreak(t *testing.T) {
+	event := func(token, name string) bson.D {
+		return bson.D{
+			{Key: "_id", Value: bson.D{{Key: "_data", Value: token}}},
+			{Key: "operationType", Value: "insert"},
+			{Key: "fullDocument", Value: bson.D{{Key: "name", Value: name}}},
+		}
+	}
+	reply := cursorReply(event("1", "a"), event("2", "b"))
+	reply[1].Value = append(reply[1].Value.(bson.D), bson.E{Key: "postBatchResumeToken", Value: bson.D{{Key: "_data", Value: "2"}}})
+	c, _ := mockCollection[testProfile](t, reply)
+	store := &testTokens{}
+	s, err := c.Watch(context.Background(), ResumeWith(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for ev, err := range s.All(context.Background()) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ev.FullDocument.Name != "a" {
+			t.Errorf("got %+v", ev.FullDocument)
+		}
+		break
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want, err := bson.Marshal(bson.D{{Key: "_data", Value: "1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(store.saved) != 1 || !bytes.Equal(store.saved[0], want) {
+		t.Errorf("got saved tokens %v, want the token of the handled event", store.saved)
+	}
+}
+
+func TestIndexKeyValue(t *testing.T) {
+	for _, v := range []any{int32(-1), int64(-1), -1, float64(-1)} {
+		if got := indexKeyValue(v); got != "-1" {
+			t.Errorf("%T: got %q, want -1", v, got)
+		}
+	}
+	if got := indexKeyValue("text"); got != "text" {
+		t.Errorf("got %q, want text", got)
+	}
+}
+
+func TestSameIndex(t *testing.T) {
+	base := IndexSpec{
+		Name:    "a_1",
+		Keys:    []IndexKey{{Field: "a", Value: int32(1)}, {Field: "b", Value: int32(-1)}},
+		Unique:  true,
+		TTL:     time.Hour,
+		Partial: bson.D{{Key: "a", Value: bson.D{{Key: "$exists", Value: true}}}},
+	}
+	tests := []struct {
+		name   string
+		change func(*IndexSpec)
+		same   bool
+	}{
+		{"identical", func(*IndexSpec) {}, true},
+		{"other name", func(s *IndexSpec) { s.Name = "other" }, true},
+		{"double directions", func(s *IndexSpec) { s.Keys = []IndexKey{{Field: "a", Value: 1.0}, {Field: "b", Value: int64(-1)}} }, true},
+		{"sub-second ttl", func(s *IndexSpec) { s.TTL += 500 * time.Millisecond }, true},
+		{"key order", func(s *IndexSpec) { s.Keys = []IndexKey{s.Keys[1], s.Keys[0]} }, false},
+		{"direction", func(s *IndexSpec) { s.Keys = []IndexKey{{Field: "a", Value: int32(-1)}, s.Keys[1]} }, false},
+		{"unique", func(s *IndexSpec) { s.Unique = false }, false},
+		{"sparse", func(s *IndexSpec) { s.Sparse = true }, false},
+		{"ttl", func(s *IndexSpec) { s.TTL = 2 * time.Hour }, false},
+		{"partial", func(s *IndexSpec) { s.Partial = nil }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			other := base
+			other.Keys = slices.Clone(base.Keys)
+			tt.change(&other)
+			if got := sameIndex(base, other); got != tt.same {
+				t.Errorf("got %v, want %v", got, tt.same)
+			}
+		})
+	}
+}
+
+func TestDiffIndexes(t *testing.T) {
+	type account struct {
+		Email string `monarch:"email" index:"unique"`
+		Name  string `monarch:"name" index:"name=by_name"`
+		Age   int    `monarch:"age" index:""`
+	}
+	s, err := parse(&account{}, &sync.Map{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	live := []IndexSpec{
+		{Name: "_id_", Keys: []IndexKey{{Field: "_id", Value: int32(1)}}},
+		{Name: "email_1", Keys: []IndexKey{{Field: "email", Value: int32(1)}}},
+		{Name: "name_1", Keys: []IndexKey{{Field: "name", Value: 1.0}}},
+		{Name: "legacy_1", Keys: []IndexKey{{Field: "legacy", Value: int32(1)}}},
+	}
+	got := diffIndexes(s, live, false)
+	want := []IndexChange{
+		{Collection: s.Collection, Action: IndexModify, Name: "email_1", Declared: &s.Indexes[0], Live: &live[1]},
+		{Collection: s.Collection, Action: IndexModify, Name: "by_name", Declared: &s.Indexes[1], Live: &live[2]},
+		{Collection: s.Collection, Action: IndexCreate, Name: "age_1", Declared: &s.Indexes[2]},
+		{Collection: s.Collection, Action: IndexDrop, Name: "legacy_1", Live: &live[3]},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+
+	if got := diffIndexes(s, live, true); !reflect.DeepEqual(got, want[:3]) {
+		t.Errorf("keep unknown: got %v, want %v", got, want[:3])
+	}
+
+	synced := slices.Clone(s.Indexes)
+	if got := diffIndexes(s, append(synced, live[0]), false); len(got) != 0 {
+		t.Errorf("in sync: got %v", got)
+	}
+}
+
+func TestListIndexes(t *testing.T) {
+	c, _ := mockCollection[testProfile](t, cursorReply(
+		bson.D{{Key: "v", Value: 2}, {Key: "key", Value: bson.D{{Key: "_id", Value: 1}}}, {Key: "name", Value: "_id_"}},
+		bson.D{{Key: "v", Value: 2}, {Key: "key", Value: bson.D{{Key: "a", Value: 1.0}, {Key: "b", Value: int64(-1)}}}, {Key: "name", Value: "a_b"}, {Key: "unique", Value: true}},
+		bson.D{{Key: "v", Value: 2}, {Key: "key", Value: bson.D{{Key: "at", Value: 1}}}, {Key: "name", Value: "at_1"}, {Key: "expireAfterSeconds", Value: int32(3600)}},
+		bson.D{
+			{Key: "v", Value: 2},
+			{Key: "key", Value: bson.D{{Key: "_fts", Value: "text"}, {Key: "_ftsx", Value: 1}}},
+			{Key: "name", Value: "bio_text"},
+			{Key: "weights", Value: bson.D{{Key: "bio", Value: 1}}},
+		},
+	))
+	specs, err := listIndexes(context.Background(), c.coll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []IndexSpec{
+		{Name: "_id_", Keys: []IndexKey{{Field: "_id", Value: int32(1)}}},
+		{Name: "a_b", Keys: []IndexKey{{Field: "a", Value: 1.0}, {Field: "b", Value: int64(-1)}}, Unique: true},
+		{Name: "at_1", Keys: []IndexKey{{Field: "at", Value: int32(1)}}, TTL: time.Hour},
+		{Name: "bio_text", Keys: []IndexKey{{Field: "bio", Value: "text"}}},
+	}
+	if !reflect.DeepEqual(specs, want) {
+		t.Errorf("got %+v\nwant %+v", specs, want)
+	}
+	declared := IndexSpec{Keys: []IndexKey{{Field: "bio", Value: "text"}}}
+	if !sameIndex(specs[3], declared) {
+		t.Error("text index does not match its declaration")
+	}
+}
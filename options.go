@@ -2,8 +2,10 @@ package monarch
 
 import (
 	"errors"
+	"strings"
 
 	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
 type OrderType int
@@ -13,92 +15,316 @@ const (
 	DESC
 )
 
+type deletedScope int
+
+const (
+	excludeDeleted deletedScope = iota
+	includeDeleted
+	onlyDeleted
+)
+
 type querier struct {
-	limit  int64
-	offset int64
-	filter bson.D
-	order  bson.D
+	limit     int64
+	offset    int64
+	filter    bson.D
+	order     bson.D
+	partial   bool
+	upsert    bool
+	after     bool
+	deleted   deletedScope
+	preload   []string
+	batch     int32
+	noTimeout bool
+	watch     watchConfig
 }
 
 type QueryOptions func(q *querier) error
 
+func newQuerier(query ...QueryOptions) (*querier, error) {
+	q := &querier{
+		filter: make(bson.D, 0),
+		order:  make(bson.D, 0),
+	}
+	for _, opt := range query {
+		if err := opt(q); err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
 func Equals(key string, value any) QueryOptions {
 	return func(q *querier) error {
+		if q.hasKey(key) {
+			q.operator(key, "$eq", value)
+			return nil
+		}
 		q.filter = append(q.filter, bson.E{Key: key, Value: value})
 		return nil
 	}
 }
 
-func Size() QueryOptions {
+func NotEquals(key string, value any) QueryOptions {
+	return func(q *querier) error {
+		q.operator(key, "$ne", value)
+		return nil
+	}
+}
+
+func Size(key string, size int) QueryOptions {
+	return func(q *querier) error {
+		if size < 0 {
+			return errors.New("error, size must not be negative")
+		}
+		q.operator(key, "$size", size)
+		return nil
+	}
+}
+
+func All(key string, values ...any) QueryOptions {
+	return func(q *querier) error {
+		q.appendOperator(key, "$all", values...)
+		return nil
+	}
+}
+
+func Contains(key string, value any) QueryOptions {
+	return func(q *querier) error {
+		q.appendOperator(key, "$all", value)
+		return nil
+	}
+}
+
+// ElemMatch matches documents where at least one element of the array at key
+// satisfies every condition in query. Conditions on the element itself, for
+// arrays of scalars, are written with an empty key, e.g. GreaterThan("", 5).
+func ElemMatch(key string, query ...QueryOptions) QueryOptions {
+	return func(q *querier) error {
+		if len(query) == 0 {
+			return errors.New("error, elemMatch requires at least one query")
+		}
+		sub, err := newQuerier(query...)
+		if err != nil {
+			return err
+		}
+		cond := sub.filter
+		if len(cond) == 1 && cond[0].Key == "" {
+			d, ok := cond[0].Value.(bson.D)
+			if !ok || !isOperatorDoc(d) {
+				d = bson.D{{Key: "$eq", Value: cond[0].Value}}
+			}
+			cond = d
+		}
+		q.operator(key, "$elemMatch", cond)
+		return nil
+	}
+}
+
+func In(key string, values ...any) QueryOptions {
 	return func(q *querier) error {
-		panic("unimplemented")
+		q.operator(key, "$in", append(bson.A{}, values...))
+		return nil
 	}
 }
 
-func In() QueryOptions {
+func NotIn(key string, values ...any) QueryOptions {
 	return func(q *querier) error {
-		panic("unimplemented")
+		q.operator(key, "$nin", append(bson.A{}, values...))
+		return nil
 	}
 }
 
-func LessThanEqual() QueryOptions {
+func LessThanEqual(key string, value any) QueryOptions {
 	return func(q *querier) error {
-		panic("unimplemented")
+		q.operator(key, "$lte", value)
+		return nil
 	}
 }
 
-func GreaterThanEqual() QueryOptions {
+func GreaterThanEqual(key string, value any) QueryOptions {
 	return func(q *querier) error {
-		panic("unimplemented")
+		q.operator(key, "$gte", value)
+		return nil
 	}
 }
 
-func LessThan() QueryOptions {
+func LessThan(key string, value any) QueryOptions {
 	return func(q *querier) error {
-		panic("unimplemented")
+		q.operator(key, "$lt", value)
+		return nil
 	}
 }
 
-func GreaterThan() QueryOptions {
+func GreaterThan(key string, value any) QueryOptions {
 	return func(q *querier) error {
-		panic("unimplemented")
+		q.operator(key, "$gt", value)
+		return nil
 	}
 }
 
-func Regex() QueryOptions {
+func Regex(key, pattern, options string) QueryOptions {
 	return func(q *querier) error {
-		panic("unimplemented")
+		q.operator(key, "$regex", bson.Regex{Pattern: pattern, Options: options})
+		return nil
 	}
 }
 
-func Or() QueryOptions {
+func Or(query ...QueryOptions) QueryOptions {
 	return func(q *querier) error {
-		panic("unimplemented")
+		branches, err := subFilters(query)
+		if err != nil {
+			return err
+		}
+		q.logical("$or", branches)
+		return nil
 	}
 }
 
-func Not() QueryOptions {
+func And(query ...QueryOptions) QueryOptions {
 	return func(q *querier) error {
-		panic("unimplemented")
+		branches, err := subFilters(query)
+		if err != nil {
+			return err
+		}
+		q.logical("$and", branches)
+		return nil
+	}
+}
+
+func Nor(query ...QueryOptions) QueryOptions {
+	return func(q *querier) error {
+		branches, err := subFilters(query)
+		if err != nil {
+			return err
+		}
+		q.logical("$nor", branches)
+		return nil
+	}
+}
+
+// Not negates query. A single field condition such as GreaterThan is negated
+// in place with $not, anything else is wrapped in a $nor clause. A field
+// negated twice gets the second condition as a $nor clause, so that neither
+// is lost.
+func Not(query QueryOptions) QueryOptions {
+	return func(q *querier) error {
+		if query == nil {
+			return errors.New("error, not requires a query")
+		}
+		sub, err := newQuerier(query)
+		if err != nil {
+			return err
+		}
+		if len(sub.filter) == 1 && !strings.HasPrefix(sub.filter[0].Key, "$") {
+			key := sub.filter[0].Key
+			if cond, ok := sub.filter[0].Value.(bson.D); ok && isOperatorDoc(cond) && !q.hasOperator(key, "$not") {
+				q.operator(key, "$not", cond)
+				return nil
+			}
+		}
+		q.logical("$nor", bson.A{sub.filter})
+		return nil
+	}
+}
+
+func (o OrderType) direction() (int32, error) {
+	switch o {
+	case ASC:
+		return 1, nil
+	case DESC:
+		return -1, nil
+	default:
+		return 0, errors.New("error, unrecognized order")
 	}
 }
 
 func OrderBy(key string, val OrderType) QueryOptions {
 	return func(q *querier) error {
-		var order int64
-		switch val {
-		case ASC:
-			order = -1
-		case DESC:
-			order = 1
-		default:
-			return errors.New("error, unrecognized order")
+		order, err := val.direction()
+		if err != nil {
+			return err
 		}
 		q.order = append(q.order, bson.E{Key: key, Value: order})
 		return nil
 	}
 }
 
+// Partial makes UpdateOne and UpdateMany set only the non-zero fields of the
+// given data instead of replacing every field.
+func Partial() QueryOptions {
+	return func(q *querier) error {
+		q.partial = true
+		return nil
+	}
+}
+
+// WithUpsert makes UpdateOneWith, ReplaceOne, FindOneAndUpdate,
+// FindOneAndReplace and the BulkWriter updates and replaces insert a document
+// when none matches. FindOneAndUpdate and FindOneAndReplace then return the
+// document after the write, so that an insert returns the new document rather
+// than ErrNotFound.
+func WithUpsert() QueryOptions {
+	return func(q *querier) error {
+		q.upsert = true
+		return nil
+	}
+}
+
+// ReturnAfter makes the FindOneAnd* methods return the document as it is
+// after the write rather than before it. It is implied by WithUpsert.
+func ReturnAfter() QueryOptions {
+	return func(q *querier) error {
+		q.after = true
+		return nil
+	}
+}
+
+// WithDeleted includes soft deleted documents in the query.
+func WithDeleted() QueryOptions {
+	return func(q *querier) error {
+		q.deleted = includeDeleted
+		return nil
+	}
+}
+
+// OnlyDeleted restricts the query to soft deleted documents.
+func OnlyDeleted() QueryOptions {
+	return func(q *querier) error {
+		q.deleted = onlyDeleted
+		return nil
+	}
+}
+
+// Preload loads the named relation fields of the documents returned by
+// FindOne and FindMany.
+func Preload(relations ...string) QueryOptions {
+	return func(q *querier) error {
+		q.preload = append(q.preload, relations...)
+		return nil
+	}
+}
+
+// BatchSize sets how many documents the server returns per batch when
+// iterating over results.
+func BatchSize(size int32) QueryOptions {
+	return func(q *querier) error {
+		if size < 0 {
+			return errors.New("error, batch size must not be negative")
+		}
+		q.batch = size
+		return nil
+	}
+}
+
+// NoCursorTimeout keeps the server from closing an idle cursor, for long
+// running iterations. The cursor must then be closed by the caller.
+func NoCursorTimeout() QueryOptions {
+	return func(q *querier) error {
+		q.noTimeout = true
+		return nil
+	}
+}
+
 func Limit(limit int64) QueryOptions {
 	return func(q *querier) error {
 		q.limit = limit
@@ -112,3 +338,130 @@ func Skip(skip int64) QueryOptions {
 		return nil
 	}
 }
+
+func (q *querier) hasKey(key string) bool {
+	for _, e := range q.filter {
+		if e.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// hasOperator reports whether the condition document of key holds op.
+func (q *querier) hasOperator(key, op string) bool {
+	for _, e := range q.filter {
+		if cond, ok := e.Value.(bson.D); ok && e.Key == key && isOperatorDoc(cond) {
+			for _, c := range cond {
+				if c.Key == op {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// operator merges op into the condition document of key, so that several
+// conditions on the same field end up in a single {key: {$op: value, ...}}
+// clause. A plain value previously set by Equals is rewritten to $eq. When
+// the field already has op, the later condition is nested under $and so that
+// both apply rather than the first being replaced.
+func (q *querier) operator(key, op string, value any) {
+	for i, e := range q.filter {
+		if e.Key != key {
+			continue
+		}
+		cond, ok := e.Value.(bson.D)
+		if !ok || !isOperatorDoc(cond) {
+			cond = bson.D{{Key: "$eq", Value: e.Value}}
+		}
+		for _, c := range cond {
+			if c.Key == op {
+				q.logical("$and", bson.A{bson.D{{Key: key, Value: bson.D{{Key: op, Value: value}}}}})
+				return
+			}
+		}
+		q.filter[i].Value = append(cond, bson.E{Key: op, Value: value})
+		return
+	}
+	q.filter = append(q.filter, bson.E{Key: key, Value: bson.D{{Key: op, Value: value}}})
+}
+
+// appendOperator is like operator but accumulates values into the array held
+// by op instead of adding a second condition, e.g. repeated Contains calls on
+// one key.
+func (q *querier) appendOperator(key, op string, values ...any) {
+	for _, e := range q.filter {
+		if e.Key != key {
+			continue
+		}
+		if cond, ok := e.Value.(bson.D); ok && isOperatorDoc(cond) {
+			for j, c := range cond {
+				if existing, ok := c.Value.(bson.A); ok && c.Key == op {
+					cond[j].Value = append(existing, values...)
+					return
+				}
+			}
+		}
+		break
+	}
+	q.operator(key, op, append(bson.A{}, values...))
+}
+
+func isOperatorDoc(d bson.D) bool {
+	return len(d) > 0 && strings.HasPrefix(d[0].Key, "$")
+}
+
+// logical adds a top level $or, $and or $nor clause. Repeating $or or $nor
+// would produce a duplicate key, so the later clause is nested under $and.
+func (q *querier) logical(op string, branches bson.A) {
+	for i, e := range q.filter {
+		if e.Key != op {
+			continue
+		}
+		if op == "$and" {
+			q.filter[i].Value = append(e.Value.(bson.A), branches...)
+			return
+		}
+		q.logical("$and", bson.A{bson.D{{Key: op, Value: branches}}})
+		return
+	}
+	q.filter = append(q.filter, bson.E{Key: op, Value: branches})
+}
+
+func subFilters(query []QueryOptions) (bson.A, error) {
+	if len(query) == 0 {
+		return nil, errors.New("error, logical operator requires at least one query")
+	}
+	branches := make(bson.A, 0, len(query))
+	for _, opt := range query {
+		if opt == nil {
+			return nil, errors.New("error, nil query in logical operator")
+		}
+		sub, err := newQuerier(opt)
+		if err != nil {
+			return nil, err
+		}
+		branches = append(branches, sub.filter)
+	}
+	return branches, nil
+}
+
+func (q *querier) returnDocument() options.ReturnDocument {
+	if q.after || q.upsert {
+		return options.After
+	}
+	return options.Before
+}
+
+func (q *querier) findOptions() *options.FindOptionsBuilder {
+	opts := options.Find().SetLimit(q.limit).SetSkip(q.offset).SetSort(q.order)
+	if q.batch > 0 {
+		opts.SetBatchSize(q.batch)
+	}
+	if q.noTimeout {
+		opts.SetNoCursorTimeout(true)
+	}
+	return opts
+}
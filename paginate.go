@@ -0,0 +1,198 @@
+package monarch
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Page is one page of a keyset paginated query. Next and Prev are opaque
+// cursors for the following and preceding page, empty when there is none.
+// HasMore reports whether more documents exist past this page in the
+// direction it was read.
+type Page[T any] struct {
+	Items   []*T
+	Next    string
+	Prev    string
+	HasMore bool
+}
+
+type pageCursor struct {
+	Keys     []string `bson:"k"`
+	Values   bson.A   `bson:"v"`
+	Backward bool     `bson:"b"`
+}
+
+// Paginate returns pageSize documents matching query that come after cursor
+// in the order given by OrderBy, with _id as the final tiebreaker. An empty
+// cursor starts at the first page; Page.Next and Page.Prev can be passed back
+// to move forward or backward. Limit and Skip are ignored.
+func (c *Collection[T]) Paginate(ctx context.Context, pageSize int64, cursor string, query ...QueryOptions) (Page[T], error) {
+	var page Page[T]
+	if pageSize < 1 {
+		return page, errors.New("error, page size must be positive")
+	}
+	cfg, err := c.query(query...)
+	if err != nil {
+		return page, err
+	}
+
+	order := slices.Clone(cfg.order)
+	if !slices.ContainsFunc(order, func(e bson.E) bool { return e.Key == "_id" }) {
+		order = append(order, bson.E{Key: "_id", Value: int32(1)})
+	}
+	keys := make([]string, len(order))
+	for i, e := range order {
+		keys[i] = e.Key
+	}
+
+	var cur *pageCursor
+	if cursor != "" {
+		if cur, err = decodeCursor(cursor, keys); err != nil {
+			return page, err
+		}
+	}
+	backward := cur != nil && cur.Backward
+	if backward {
+		for i := range order {
+			order[i].Value = -toInt32(order[i].Value)
+		}
+	}
+	if cur != nil {
+		cfg.logical("$and", bson.A{keysetFilter(order, cur.Values)})
+	}
+
+	result, err := c.coll.Find(ctx, cfg.filter, options.Find().SetLimit(pageSize+1).SetSort(order))
+	if err != nil {
+		return page, c.wrapError(err)
+	}
+	defer result.Close(ctx)
+
+	var docs []bson.D
+	for result.Next(ctx) {
+		var doc bson.D
+		if err := result.Decode(&doc); err != nil {
+			return page, err
+		}
+		docs = append(docs, doc)
+	}
+	if err := result.Err(); err != nil {
+		return page, c.wrapError(err)
+	}
+
+	page.HasMore = int64(len(docs)) > pageSize
+	if page.HasMore {
+		docs = docs[:pageSize]
+	}
+	if backward {
+		slices.Reverse(docs)
+	}
+	if len(docs) == 0 {
+		return page, nil
+	}
+
+	for _, doc := range docs {
+		item, err := c.decode(ctx, doc)
+		if err != nil {
+			return page, err
+		}
+		page.Items = append(page.Items, item)
+	}
+	if err := c.preload(ctx, page.Items, cfg.preload); err != nil {
+		return page, err
+	}
+
+	if backward || page.HasMore {
+		if page.Next, err = encodeCursor(keys, docs[len(docs)-1], false); err != nil {
+			return page, err
+		}
+	}
+	if (backward && page.HasMore) || (!backward && cur != nil) {
+		if page.Prev, err = encodeCursor(keys, docs[0], true); err != nil {
+			return page, err
+		}
+	}
+	return page, nil
+}
+
+// keysetFilter matches the documents sorting after values, i.e.
+// k1 > v1 OR (k1 = v1 AND k2 > v2) OR ..., with < for descending keys.
+func keysetFilter(order bson.D, values bson.A) bson.D {
+	branches := make(bson.A, 0, len(order))
+	for i, e := range order {
+		branch := make(bson.D, 0, i+1)
+		for j := range i {
+			branch = append(branch, bson.E{Key: order[j].Key, Value: values[j]})
+		}
+		op := "$gt"
+		if toInt32(e.Value) < 0 {
+			op = "$lt"
+		}
+		branch = append(branch, bson.E{Key: e.Key, Value: bson.D{{Key: op, Value: values[i]}}})
+		branches = append(branches, branch)
+	}
+	return bson.D{{Key: "$or", Value: branches}}
+}
+
+func encodeCursor(keys []string, doc bson.D, backward bool) (string, error) {
+	values := make(bson.A, len(keys))
+	for i, k := range keys {
+		values[i] = lookupPath(doc, k)
+	}
+	b, err := bson.Marshal(pageCursor{Keys: keys, Values: values, Backward: backward})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeCursor(cursor string, keys []string) (*pageCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+	var cur pageCursor
+	if err := bson.Unmarshal(b, &cur); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+	if !slices.Equal(cur.Keys, keys) || len(cur.Values) != len(keys) {
+		return nil, fmt.Errorf("%w: cursor was created for a different order", ErrInvalidCursor)
+	}
+	return &cur, nil
+}
+
+func lookupPath(doc bson.D, path string) any {
+	var v any = doc
+	for _, key := range strings.Split(path, ".") {
+		d, ok := v.(bson.D)
+		if !ok {
+			return nil
+		}
+		v = nil
+		for _, e := range d {
+			if e.Key == key {
+				v = e.Value
+				break
+			}
+		}
+	}
+	return v
+}
+
+func toInt32(v any) int32 {
+	switch v := v.(type) {
+	case int32:
+		return v
+	case int64:
+		return int32(v)
+	case int:
+		return int32(v)
+	}
+	return 1
+}
@@ -0,0 +1,182 @@
+package monarch
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Relation links a field that is not stored, such as
+//
+//	Author *User `monarch:"-,rel=author_id"`
+//
+// to the document of another collection its local key refers to, declared as
+//
+//	AuthorID string `monarch:"author_id,ref=users"`
+//
+// Relations are loaded on demand with the Preload query option.
+type Relation struct {
+	Field      *Field
+	LocalKey   *Field
+	Collection string
+	ForeignKey string
+	Target     reflect.Type
+	Many       bool
+}
+
+func (schema *Schema) parseRelation(field *Field) (*Relation, error) {
+	local, ok := schema.FieldByDBName[field.Rel]
+	if !ok {
+		return nil, fmt.Errorf("%w: relation %s refers to unknown field %s", ErrInvalidSchema, field.Name, field.Rel)
+	}
+	if local.Ref == "" {
+		return nil, fmt.Errorf("%w: field %s used by relation %s has no ref", ErrInvalidSchema, local.Name, field.Name)
+	}
+
+	rel := &Relation{
+		Field:      field,
+		LocalKey:   local,
+		Collection: local.Ref,
+		ForeignKey: local.RefKey,
+		Target:     field.IndirectFieldType,
+	}
+	if rel.Target.Kind() == reflect.Slice {
+		rel.Many = true
+		rel.Target = rel.Target.Elem()
+		for rel.Target.Kind() == reflect.Pointer {
+			rel.Target = rel.Target.Elem()
+		}
+	}
+	if rel.Target.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: relation %s must be a struct, pointer or slice of structs, got %v", ErrInvalidSchema, field.Name, field.FieldType)
+	}
+
+	// the keys index the loaded documents in a map, and only a slice relation
+	// can hold the documents of several keys
+	key := local.IndirectFieldType
+	if key.Kind() == reflect.Slice {
+		if !rel.Many {
+			return nil, fmt.Errorf("%w: relation %s holds one document but its key %s is a slice", ErrInvalidSchema, field.Name, local.Name)
+		}
+		key = key.Elem()
+	}
+	if !key.Comparable() {
+		return nil, fmt.Errorf("%w: key %s of relation %s is not comparable, got %v", ErrInvalidSchema, local.Name, field.Name, local.FieldType)
+	}
+	return rel, nil
+}
+
+// preload loads the named relations of results with one $in query per
+// relation.
+func (c *Collection[T]) preload(ctx context.Context, results []*T, names []string) error {
+	if len(results) == 0 || len(names) == 0 {
+		return nil
+	}
+	s, err := c.schema()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		rel, ok := s.Relations[name]
+		if !ok {
+			return fmt.Errorf("error, unknown relation %s on %s", name, s.Name)
+		}
+		target, err := parse(reflect.New(rel.Target).Interface(), c.cacheStore)
+		if err != nil {
+			return err
+		}
+		foreign, ok := target.FieldByDBName[rel.ForeignKey]
+		if !ok {
+			return fmt.Errorf("%w: relation %s refers to unknown field %s on %s", ErrInvalidSchema, name, rel.ForeignKey, target.Name)
+		}
+
+		keys := make(bson.A, 0, len(results))
+		for _, r := range results {
+			v := rel.LocalKey.ReflectValueOf(ctx, reflect.ValueOf(r))
+			if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+				for i := range v.Len() {
+					keys = append(keys, v.Index(i).Interface())
+				}
+			} else if !v.IsZero() {
+				keys = append(keys, v.Interface())
+			}
+		}
+		if len(keys) == 0 {
+			continue
+		}
+
+		filter := bson.D{{Key: rel.ForeignKey, Value: bson.D{{Key: "$in", Value: keys}}}}
+		if target.DeletedAt != nil {
+			filter = append(filter, bson.E{Key: target.DeletedAt.DBName, Value: nil})
+		}
+		cur, err := c.coll.Database().Collection(rel.Collection).Find(ctx, filter)
+		if err != nil {
+			return c.wrapError(err)
+		}
+		related := make(map[any]reflect.Value)
+		for cur.Next(ctx) {
+			var doc bson.D
+			if err := cur.Decode(&doc); err != nil {
+				cur.Close(ctx)
+				return err
+			}
+			v, err := decodeDocument(ctx, doc, rel.Target, c.cacheStore)
+			if err != nil {
+				cur.Close(ctx)
+				return err
+			}
+			related[foreign.ReflectValueOf(ctx, v).Interface()] = v
+		}
+		err = cur.Err()
+		cur.Close(ctx)
+		if err != nil {
+			return c.wrapError(err)
+		}
+
+		for _, r := range results {
+			rel.assign(ctx, reflect.ValueOf(r), related)
+		}
+	}
+	return nil
+}
+
+func (rel *Relation) assign(ctx context.Context, r reflect.Value, related map[any]reflect.Value) {
+	local := rel.LocalKey.ReflectValueOf(ctx, r)
+	dest := rel.Field.ReflectValueOf(ctx, r)
+
+	set := func(dest reflect.Value, v reflect.Value) {
+		if dest.Kind() == reflect.Pointer {
+			dest.Set(v)
+		} else {
+			dest.Set(v.Elem())
+		}
+	}
+
+	if !rel.Many {
+		if v, ok := related[local.Interface()]; ok {
+			set(dest, v)
+		}
+		return
+	}
+
+	var keys []any
+	if local.Kind() == reflect.Slice || local.Kind() == reflect.Array {
+		for i := range local.Len() {
+			keys = append(keys, local.Index(i).Interface())
+		}
+	} else {
+		keys = append(keys, local.Interface())
+	}
+	items := reflect.MakeSlice(rel.Field.FieldType, 0, len(keys))
+	for _, k := range keys {
+		if v, ok := related[k]; ok {
+			elem := reflect.New(rel.Field.FieldType.Elem()).Elem()
+			set(elem, v)
+			items = reflect.Append(items, elem)
+		}
+	}
+	dest.Set(items)
+}
@@ -0,0 +1,34 @@
+package monarch
+
+import "go.mongodb.org/mongo-driver/v2/mongo"
+
+// Result reports what a write did. Only the counts relevant to the operation
+// are set.
+type Result struct {
+	InsertedID    any
+	InsertedIDs   []any
+	UpsertedID    any
+	UpsertedIDs   map[int]any
+	InsertedCount int64
+	MatchedCount  int64
+	ModifiedCount int64
+	UpsertedCount int64
+	DeletedCount  int64
+}
+
+func insertResult(r *mongo.InsertOneResult) *Result {
+	return &Result{InsertedID: r.InsertedID, InsertedCount: 1}
+}
+
+func updateResult(r *mongo.UpdateResult) *Result {
+	return &Result{
+		UpsertedID:    r.UpsertedID,
+		MatchedCount:  r.MatchedCount,
+		ModifiedCount: r.ModifiedCount,
+		UpsertedCount: r.UpsertedCount,
+	}
+}
+
+func deleteResult(r *mongo.DeleteResult) *Result {
+	return &Result{DeletedCount: r.DeletedCount}
+}
@@ -1,7 +1,6 @@
 package monarch
 
 import (
-	"errors"
 	"fmt"
 	"go/ast"
 	"reflect"
@@ -18,6 +17,9 @@ type Schema struct {
 	FieldByName   map[string]*Field
 	FieldByDBName map[string]*Field
 	IndexField    map[string]*Field
+	Indexes       []IndexSpec
+	DeletedAt     *Field
+	Relations     map[string]*Relation
 
 	cacheStore *sync.Map
 	err        error
@@ -27,7 +29,7 @@ type Schema struct {
 func parse(obj any, cacheStore *sync.Map) (*Schema, error) {
 
 	if obj == nil {
-		return nil, errors.New("err: unexpected type")
+		return nil, fmt.Errorf("%w: unexpected nil type", ErrInvalidSchema)
 	}
 
 	value := reflect.ValueOf(obj)
@@ -43,9 +45,9 @@ func parse(obj any, cacheStore *sync.Map) (*Schema, error) {
 
 	if schemaType.Kind() != reflect.Struct {
 		if schemaType.PkgPath() == "" {
-			return nil, errors.New("")
+			return nil, fmt.Errorf("%w: %s is not a struct", ErrInvalidSchema, schemaType)
 		}
-		return nil, errors.New("")
+		return nil, fmt.Errorf("%w: %s.%s is not a struct", ErrInvalidSchema, schemaType.PkgPath(), schemaType.Name())
 	}
 
 	if v, ok := cacheStore.Load(schemaType); ok {
@@ -63,6 +65,7 @@ func parse(obj any, cacheStore *sync.Map) (*Schema, error) {
 		FieldByName:   make(map[string]*Field),
 		FieldByDBName: make(map[string]*Field),
 		IndexField:    make(map[string]*Field),
+		Relations:     make(map[string]*Relation),
 		cacheStore:    cacheStore,
 		loaded:        make(chan struct{}),
 	}
@@ -76,11 +79,17 @@ func parse(obj any, cacheStore *sync.Map) (*Schema, error) {
 		return s, s.err
 	}
 
+	var relations []*Field
 	for i := range schemaType.NumField() {
 		if fieldStruct := schemaType.Field(i); ast.IsExported(fieldStruct.Name) {
-			if field := schema.parseField(fieldStruct); field.EmbeddedSchema != nil {
+			field := schema.parseField(fieldStruct)
+			switch {
+			case field == nil:
+			case field.Rel != "":
+				relations = append(relations, field)
+			case field.EmbeddedSchema != nil:
 				schema.Fields = append(schema.Fields, field.EmbeddedSchema.Fields...)
-			} else {
+			default:
 				schema.Fields = append(schema.Fields, field)
 			}
 		}
@@ -89,12 +98,29 @@ func parse(obj any, cacheStore *sync.Map) (*Schema, error) {
 		if field.Index {
 			schema.IndexField[field.Name] = field
 		}
+		if field.SoftDelete {
+			if field.FieldType != tTime {
+				schema.err = fmt.Errorf("%w: soft delete field %s must be time.Time", ErrInvalidSchema, field.Name)
+			}
+			schema.DeletedAt = field
+		}
 		if field.DBName != "" {
 			schema.FieldByDBName[field.DBName] = field
 		}
 		schema.FieldByName[field.Name] = field
 		field.setupValuerAndSetter()
 	}
+	if err := schema.buildIndexes(); err != nil && schema.err == nil {
+		schema.err = err
+	}
+	for _, field := range relations {
+		field.setupValuerAndSetter()
+		if rel, err := schema.parseRelation(field); err != nil {
+			schema.err = err
+		} else {
+			schema.Relations[field.Name] = rel
+		}
+	}
 
 	if v, ok := cacheStore.LoadOrStore(schemaType, schema); ok {
 		s := v.(*Schema)
@@ -120,9 +146,9 @@ func getOrParse(dest interface{}, cacheStore *sync.Map) (*Schema, error) {
 
 	if modelType.Kind() != reflect.Struct {
 		if modelType.PkgPath() == "" {
-			return nil, fmt.Errorf("%w: %+v", errors.New("unsupported data type"), dest)
+			return nil, fmt.Errorf("%w: unsupported data type %+v", ErrInvalidSchema, dest)
 		}
-		return nil, fmt.Errorf("%w: %s.%s", errors.New("unsupported data type"), modelType.PkgPath(), modelType.Name())
+		return nil, fmt.Errorf("%w: unsupported data type %s.%s", ErrInvalidSchema, modelType.PkgPath(), modelType.Name())
 	}
 
 	if v, ok := cacheStore.Load(modelType); ok {
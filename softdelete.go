@@ -0,0 +1,126 @@
+package monarch
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// query builds the querier for query. When T embeds SoftDelete the filter is
+// scoped to documents that are not deleted, unless WithDeleted or OnlyDeleted
+// is given.
+func (c *Collection[T]) query(query ...QueryOptions) (*querier, error) {
+	cfg, err := newQuerier(query...)
+	if err != nil {
+		return nil, err
+	}
+	f, err := c.deletedAtField()
+	if err != nil {
+		return nil, err
+	}
+	if f == nil {
+		return cfg, nil
+	}
+	switch cfg.deleted {
+	case excludeDeleted:
+		err = Equals(f.DBName, nil)(cfg)
+	case onlyDeleted:
+		err = NotEquals(f.DBName, nil)(cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Collection[T]) deletedAtField() (*Field, error) {
+	s, err := c.schema()
+	if err != nil {
+		return nil, err
+	}
+	return s.DeletedAt, nil
+}
+
+func (c *Collection[T]) softDeleteUpdate(f *Field) bson.D {
+	return bson.D{{Key: "$set", Value: bson.D{{Key: f.DBName, Value: c.timestamp()}}}}
+}
+
+// remove deletes the documents matching filter, or marks them as deleted
+// when T embeds SoftDelete.
+func (c *Collection[T]) remove(ctx context.Context, filter bson.D, many bool) (*Result, error) {
+	f, err := c.deletedAtField()
+	if err != nil {
+		return nil, err
+	}
+	if f == nil {
+		var res *mongo.DeleteResult
+		if many {
+			res, err = c.coll.DeleteMany(ctx, filter)
+		} else {
+			res, err = c.coll.DeleteOne(ctx, filter)
+		}
+		if err != nil {
+			return nil, c.wrapError(err)
+		}
+		return deleteResult(res), nil
+	}
+
+	var res *mongo.UpdateResult
+	if many {
+		res, err = c.coll.UpdateMany(ctx, filter, c.softDeleteUpdate(f))
+	} else {
+		res, err = c.coll.UpdateOne(ctx, filter, c.softDeleteUpdate(f))
+	}
+	if err != nil {
+		return nil, c.wrapError(err)
+	}
+	result := updateResult(res)
+	result.DeletedCount = res.ModifiedCount
+	return result, nil
+}
+
+// Restore clears DeletedAt on the soft deleted documents matching query.
+func (c *Collection[T]) Restore(ctx context.Context, query ...QueryOptions) (*Result, error) {
+	f, err := c.deletedAtField()
+	if err != nil {
+		return nil, err
+	}
+	if f == nil {
+		return &Result{}, nil
+	}
+	cfg, err := c.query(append([]QueryOptions{OnlyDeleted()}, query...)...)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.coll.UpdateMany(ctx, cfg.filter, bson.D{{Key: "$unset", Value: bson.D{{Key: f.DBName, Value: ""}}}})
+	if err != nil {
+		return nil, c.wrapError(err)
+	}
+	return updateResult(res), nil
+}
+
+// ForceDelete removes the documents matching query from the collection,
+// including soft deleted ones, regardless of SoftDelete. Like DeleteMany it
+// passes the documents to the delete hooks of T.
+func (c *Collection[T]) ForceDelete(ctx context.Context, query ...QueryOptions) (*Result, error) {
+	cfg, err := c.query(append([]QueryOptions{WithDeleted()}, query...)...)
+	if err != nil {
+		return nil, err
+	}
+	var docs []*T
+	filter := cfg.filter
+	if hasDeleteHooks[T]() {
+		if docs, filter, err = c.beforeDelete(ctx, filter, 0); err != nil {
+			return nil, err
+		}
+	}
+	res, err := c.coll.DeleteMany(ctx, filter)
+	if err != nil {
+		return nil, c.wrapError(err)
+	}
+	if err := c.afterDelete(ctx, docs); err != nil {
+		return nil, err
+	}
+	return deleteResult(res), nil
+}
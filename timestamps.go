@@ -0,0 +1,131 @@
+package monarch
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+var tTime = reflect.TypeOf(time.Time{})
+
+func (c *Collection[T]) timestamp() time.Time {
+	now := time.Now
+	if c.now != nil {
+		now = c.now
+	}
+	// mongo stores dates with millisecond precision
+	return now().Truncate(time.Millisecond)
+}
+
+// touch stamps the autoUpdateTime fields of data and, when create is set, the
+// autoCreateTime fields that are still zero.
+func (c *Collection[T]) touch(ctx context.Context, data *T, create bool) error {
+	s, err := c.schema()
+	if err != nil {
+		return err
+	}
+	now := c.timestamp()
+	value := reflect.ValueOf(data)
+	for _, f := range s.Fields {
+		switch {
+		case f.AutoUpdateTime:
+		case f.AutoCreateTime && create:
+			if !f.ReflectValueOf(ctx, value).IsZero() {
+				continue
+			}
+		default:
+			continue
+		}
+		if err := setTime(f, f.ReflectValueOf(ctx, value), now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// touchUpdate adds $set of the autoUpdateTime fields to an update built from
+// UpdateOptions, unless the update already writes them. With upsert the
+// autoCreateTime fields are added with $setOnInsert.
+func (c *Collection[T]) touchUpdate(u *updater, upsert bool) error {
+	s, err := c.schema()
+	if err != nil {
+		return err
+	}
+	now := c.timestamp()
+	for _, f := range s.Fields {
+		switch {
+		case u.has(f.DBName):
+		case f.AutoUpdateTime:
+			u.operator("$set", f.DBName, timeValue(f, now))
+		case f.AutoCreateTime && upsert:
+			u.operator("$setOnInsert", f.DBName, timeValue(f, now))
+		}
+	}
+	return nil
+}
+
+// replacement builds the document written by ReplaceOne, FindOneAndReplace
+// and BulkWriter.Replace. A plain replacement would overwrite the stored
+// autoCreateTime fields, so when the schema has any, pipeline is returned
+// instead: it replaces the document but carries those fields over, stamping
+// them only when the document is inserted.
+func (c *Collection[T]) replacement(ctx context.Context, data *T) (doc bson.D, pipeline mongo.Pipeline, err error) {
+	if err := c.touch(ctx, data, false); err != nil {
+		return nil, nil, err
+	}
+	doc, err = c.marshal(ctx, *data, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	s, err := c.schema()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := c.timestamp()
+	keep := bson.D{}
+	for _, f := range s.Fields {
+		if !f.AutoCreateTime {
+			continue
+		}
+		var stamp any = timeValue(f, now)
+		if v := f.ReflectValueOf(ctx, reflect.ValueOf(data)); !v.IsZero() {
+			if stamp, err = c.encodeValue(v); err != nil {
+				return nil, nil, err
+			}
+		}
+		doc = slices.DeleteFunc(doc, func(e bson.E) bool { return e.Key == f.DBName })
+		keep = append(keep, bson.E{Key: f.DBName, Value: bson.D{{Key: "$ifNull", Value: bson.A{"$" + f.DBName, stamp}}}})
+	}
+	if len(keep) == 0 {
+		return doc, nil, nil
+	}
+	merged := bson.D{{Key: "$mergeObjects", Value: bson.A{bson.D{{Key: "$literal", Value: doc}}, keep}}}
+	return nil, mongo.Pipeline{{{Key: "$replaceWith", Value: merged}}}, nil
+}
+
+func timeValue(f *Field, now time.Time) any {
+	if f.IndirectFieldType.Kind() == reflect.Int64 {
+		return now.Unix()
+	}
+	return now
+}
+
+func setTime(f *Field, v reflect.Value, now time.Time) error {
+	switch {
+	case f.FieldType == tTime:
+		v.Set(reflect.ValueOf(now))
+	case f.FieldType.Kind() == reflect.Pointer && f.IndirectFieldType == tTime:
+		v.Set(reflect.ValueOf(&now))
+	case f.FieldType.Kind() == reflect.Int64:
+		v.SetInt(now.Unix())
+	default:
+		return fmt.Errorf("%w: auto time field %s must be time.Time, *time.Time or int64", ErrInvalidSchema, f.Name)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package monarch
+
+import (
+	"errors"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type updater struct {
+	update bson.D
+}
+
+type UpdateOption func(u *updater) error
+
+func newUpdater(updates ...UpdateOption) (*updater, error) {
+	u := &updater{
+		update: make(bson.D, 0),
+	}
+	for _, opt := range updates {
+		if err := opt(u); err != nil {
+			return nil, err
+		}
+	}
+	if len(u.update) == 0 {
+		return nil, errors.New("error, no update operators")
+	}
+	return u, nil
+}
+
+func Set(key string, value any) UpdateOption {
+	return func(u *updater) error {
+		u.operator("$set", key, value)
+		return nil
+	}
+}
+
+func SetOnInsert(key string, value any) UpdateOption {
+	return func(u *updater) error {
+		u.operator("$setOnInsert", key, value)
+		return nil
+	}
+}
+
+func Unset(key string) UpdateOption {
+	return func(u *updater) error {
+		u.operator("$unset", key, "")
+		return nil
+	}
+}
+
+func Inc(key string, value any) UpdateOption {
+	return func(u *updater) error {
+		u.operator("$inc", key, value)
+		return nil
+	}
+}
+
+func Mul(key string, value any) UpdateOption {
+	return func(u *updater) error {
+		u.operator("$mul", key, value)
+		return nil
+	}
+}
+
+func Min(key string, value any) UpdateOption {
+	return func(u *updater) error {
+		u.operator("$min", key, value)
+		return nil
+	}
+}
+
+func Max(key string, value any) UpdateOption {
+	return func(u *updater) error {
+		u.operator("$max", key, value)
+		return nil
+	}
+}
+
+func Rename(key, newKey string) UpdateOption {
+	return func(u *updater) error {
+		if newKey == "" || newKey == key {
+			return errors.New("error, invalid rename target")
+		}
+		u.operator("$rename", key, newKey)
+		return nil
+	}
+}
+
+func CurrentDate(key string) UpdateOption {
+	return func(u *updater) error {
+		u.operator("$currentDate", key, true)
+		return nil
+	}
+}
+
+// Push appends values to the array at key. Several values are pushed in one
+// operation with $each.
+func Push(key string, values ...any) UpdateOption {
+	return func(u *updater) error {
+		v, err := each(values)
+		if err != nil {
+			return err
+		}
+		u.operator("$push", key, v)
+		return nil
+	}
+}
+
+func AddToSet(key string, values ...any) UpdateOption {
+	return func(u *updater) error {
+		v, err := each(values)
+		if err != nil {
+			return err
+		}
+		u.operator("$addToSet", key, v)
+		return nil
+	}
+}
+
+// Pull removes every element of the array at key equal to value.
+func Pull(key string, value any) UpdateOption {
+	return func(u *updater) error {
+		u.operator("$pull", key, value)
+		return nil
+	}
+}
+
+func PullAll(key string, values ...any) UpdateOption {
+	return func(u *updater) error {
+		u.operator("$pullAll", key, append(bson.A{}, values...))
+		return nil
+	}
+}
+
+func (u *updater) operator(op, key string, value any) {
+	for i, e := range u.update {
+		if e.Key != op {
+			continue
+		}
+		fields := e.Value.(bson.D)
+		for j, f := range fields {
+			if f.Key == key {
+				fields[j].Value = value
+				return
+			}
+		}
+		u.update[i].Value = append(fields, bson.E{Key: key, Value: value})
+		return
+	}
+	u.update = append(u.update, bson.E{Key: op, Value: bson.D{{Key: key, Value: value}}})
+}
+
+func (u *updater) has(key string) bool {
+	for _, e := range u.update {
+		for _, f := range e.Value.(bson.D) {
+			if f.Key == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func each(values []any) (any, error) {
+	switch len(values) {
+	case 0:
+		return nil, errors.New("error, no values to add")
+	case 1:
+		return values[0], nil
+	default:
+		return bson.D{{Key: "$each", Value: bson.A(values)}}, nil
+	}
+}
@@ -0,0 +1,236 @@
+package monarch
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// TokenStore persists change stream resume tokens so that a watcher can
+// continue where it stopped after a restart. LoadToken returns a nil token
+// when nothing has been saved yet.
+type TokenStore interface {
+	LoadToken(ctx context.Context) (bson.Raw, error)
+	SaveToken(ctx context.Context, token bson.Raw) error
+}
+
+type watchConfig struct {
+	operations []string
+	lookup     bool
+	store      TokenStore
+}
+
+// OperationTypes restricts Watch to the given change events, e.g. "insert",
+// "update", "replace" or "delete".
+func OperationTypes(ops ...string) QueryOptions {
+	return func(q *querier) error {
+		q.watch.operations = append(q.watch.operations, ops...)
+		return nil
+	}
+}
+
+// UpdateLookup makes update events of Watch carry the current full document.
+func UpdateLookup() QueryOptions {
+	return func(q *querier) error {
+		q.watch.lookup = true
+		return nil
+	}
+}
+
+// ResumeWith makes Watch resume after the token held by store and save the
+// token of every event consumed through ChangeStream.All.
+func ResumeWith(store TokenStore) QueryOptions {
+	return func(q *querier) error {
+		if store == nil {
+			return errors.New("error, nil token store")
+		}
+		q.watch.store = store
+		return nil
+	}
+}
+
+type ChangeEvent[T any] struct {
+	ResumeToken       bson.Raw
+	OperationType     string
+	FullDocument      *T
+	DocumentKey       bson.D
+	UpdateDescription *UpdateDescription
+	ClusterTime       bson.Timestamp
+}
+
+type UpdateDescription struct {
+	UpdatedFields bson.D   `bson:"updatedFields"`
+	RemovedFields []string `bson:"removedFields"`
+}
+
+type changeEvent struct {
+	ID                bson.Raw           `bson:"_id"`
+	OperationType     string             `bson:"operationType"`
+	FullDocument      bson.D             `bson:"fullDocument"`
+	DocumentKey       bson.D             `bson:"documentKey"`
+	UpdateDescription *UpdateDescription `bson:"updateDescription"`
+	ClusterTime       bson.Timestamp     `bson:"clusterTime"`
+}
+
+type ChangeStream[T any] struct {
+	c     *Collection[T]
+	cs    *mongo.ChangeStream
+	store TokenStore
+	err   error
+}
+
+// Watch opens a change stream on the collection. Field conditions in query
+// apply to the full document of the event, so they never match delete events
+// and only match updates with UpdateLookup.
+func (c *Collection[T]) Watch(ctx context.Context, query ...QueryOptions) (*ChangeStream[T], error) {
+	cfg, err := newQuerier(query...)
+	if err != nil {
+		return nil, err
+	}
+
+	match := prefixFilter("fullDocument.", cfg.filter)
+	if len(cfg.watch.operations) > 0 {
+		match = append(match, bson.E{Key: "operationType", Value: bson.D{{Key: "$in", Value: cfg.watch.operations}}})
+	}
+	pipeline := mongo.Pipeline{}
+	if len(match) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: match}})
+	}
+
+	opts := options.ChangeStream()
+	if cfg.watch.lookup {
+		opts.SetFullDocument(options.UpdateLookup)
+	}
+	if cfg.batch > 0 {
+		opts.SetBatchSize(cfg.batch)
+	}
+	if store := cfg.watch.store; store != nil {
+		token, err := store.LoadToken(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if token != nil {
+			opts.SetResumeAfter(token)
+		}
+	}
+
+	cs, err := c.coll.Watch(ctx, pipeline, opts)
+	if err != nil {
+		return nil, c.wrapError(err)
+	}
+	return &ChangeStream[T]{c: c, cs: cs, store: cfg.watch.store}, nil
+}
+
+// prefixFilter rewrites the field names of filter, descending into the
+// logical operators, e.g. {email: x} to {fullDocument.email: x}.
+func prefixFilter(prefix string, filter bson.D) bson.D {
+	out := make(bson.D, 0, len(filter))
+	for _, e := range filter {
+		if !strings.HasPrefix(e.Key, "$") {
+			out = append(out, bson.E{Key: prefix + e.Key, Value: e.Value})
+			continue
+		}
+		branches, ok := e.Value.(bson.A)
+		if !ok {
+			out = append(out, e)
+			continue
+		}
+		prefixed := make(bson.A, 0, len(branches))
+		for _, b := range branches {
+			if d, ok := b.(bson.D); ok {
+				b = prefixFilter(prefix, d)
+			}
+			prefixed = append(prefixed, b)
+		}
+		out = append(out, bson.E{Key: e.Key, Value: prefixed})
+	}
+	return out
+}
+
+func (s *ChangeStream[T]) Next(ctx context.Context) bool {
+	return s.cs.Next(ctx)
+}
+
+// Event decodes the current event.
+func (s *ChangeStream[T]) Event() (*ChangeEvent[T], error) {
+	var raw changeEvent
+	if err := s.cs.Decode(&raw); err != nil {
+		return nil, s.c.wrapError(err)
+	}
+	ev := &ChangeEvent[T]{
+		ResumeToken:       raw.ID,
+		OperationType:     raw.OperationType,
+		DocumentKey:       raw.DocumentKey,
+		UpdateDescription: raw.UpdateDescription,
+		ClusterTime:       raw.ClusterTime,
+	}
+	if raw.FullDocument != nil {
+		doc, err := s.c.unMarshal(raw.FullDocument)
+		if err != nil {
+			return nil, err
+		}
+		ev.FullDocument = doc
+	}
+	return ev, nil
+}
+
+func (s *ChangeStream[T]) ResumeToken() bson.Raw {
+	return s.cs.ResumeToken()
+}
+
+// SaveToken stores the current resume token in the stream's TokenStore.
+func (s *ChangeStream[T]) SaveToken(ctx context.Context) error {
+	if s.store == nil {
+		return nil
+	}
+	return s.store.SaveToken(ctx, s.cs.ResumeToken())
+}
+
+// Err returns the error that ended the stream, or the error saving the token
+// when the loop over All stopped.
+func (s *ChangeStream[T]) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.c.wrapError(s.cs.Err())
+}
+
+func (s *ChangeStream[T]) Close(ctx context.Context) error {
+	return s.cs.Close(ctx)
+}
+
+// All yields the events of the stream until ctx is done or the loop stops,
+// then closes the stream. With ResumeWith the token of an event is saved once
+// the loop body has handled it, including when the body breaks out of the
+// loop; an error saving it then is returned by Err. Delivery is at least once:
+// an event whose token was not saved, e.g. on a crash, is delivered again
+// after a restart.
+func (s *ChangeStream[T]) All(ctx context.Context) iter.Seq2[*ChangeEvent[T], error] {
+	return func(yield func(*ChangeEvent[T], error) bool) {
+		defer s.Close(context.WithoutCancel(ctx))
+
+		for s.Next(ctx) {
+			ev, err := s.Event()
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(ev, nil) {
+				s.err = s.SaveToken(ctx)
+				return
+			}
+			if err := s.SaveToken(ctx); err != nil {
+				yield(nil, err)
+				return
+			}
+		}
+		if err := s.Err(); err != nil && ctx.Err() == nil {
+			yield(nil, err)
+		}
+	}
+}